@@ -0,0 +1,443 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Options lists the sources Load reads on top of the struct tag defaults.
+// Precedence, lowest first: defaults, YAML files, .env files, environment.
+type Options struct {
+	// Files are YAML files. Keys are either the env names themselves
+	// (DB_HOST: localhost) or nested maps that flatten to them
+	// (db: {host: localhost}). Later files override earlier ones.
+	Files []string
+	// EnvFiles are .env files. Like godotenv.Load, the first file that sets
+	// a key wins.
+	EnvFiles []string
+	// LookupEnv replaces os.LookupEnv (used by tests).
+	LookupEnv func(key string) (string, bool)
+	// CredentialsDir is searched for secret fields. It defaults to
+	// $CREDENTIALS_DIRECTORY, which systemd sets for LoadCredential=.
+	CredentialsDir string
+}
+
+// Validator is implemented by config structs that need checks beyond
+// "required". Load calls it on every struct it fills, innermost first.
+type Validator interface {
+	Validate() error
+}
+
+// Error collects every configuration problem found by Load, so a service
+// reports them all at once instead of failing on the first.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load fills dst, a pointer to a struct, from the layered sources. Fields are
+// described with tags:
+//
+//	env:"DB_HOST"        variable name (required for the field to be loaded)
+//	default:"5432"       value used when no source sets the key
+//	required:"true"      the final value must not be empty
+//	secret:"true"        masked by Entries and Fprint, and also read from
+//	                     files (see resolveSecret)
+//
+// Nested structs without an env tag are walked recursively. Supported field
+// types are string, bool, ints, uints, floats, time.Duration and []string
+// (comma separated). Empty values count as unset in every layer.
+func Load(dst any, opts Options) error {
+	root, err := structValue(dst)
+	if err != nil {
+		return err
+	}
+
+	lookup := opts.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	var problems []string
+	fileValues, fileProblems := readFiles(opts.Files)
+	problems = append(problems, fileProblems...)
+	envFileValues, envFileProblems := readEnvFiles(opts.EnvFiles)
+	problems = append(problems, envFileProblems...)
+
+	credDir := opts.CredentialsDir
+	if credDir == "" {
+		credDir, _ = lookup("CREDENTIALS_DIRECTORY")
+	}
+
+	src := &sources{
+		lookup:   lookup,
+		envFiles: envFileValues,
+		files:    fileValues,
+		credDir:  credDir,
+	}
+	problems = append(problems, fill(root, src, true)...)
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+// LoadService fills dst the way the services load their configuration:
+// Load with the YAML file named by CONFIG_FILE (default config.yaml) and
+// .env / ../.env on top of the environment.
+func LoadService(dst any) error {
+	file := os.Getenv("CONFIG_FILE")
+	if file == "" {
+		file = "config.yaml"
+	}
+	return Load(dst, Options{
+		Files:    []string{file},
+		EnvFiles: []string{".env", "../.env"},
+	})
+}
+
+// Defaults applies only the default tags to dst, ignoring every other source.
+// Validators are not run since required values are usually still missing.
+func Defaults(dst any) error {
+	root, err := structValue(dst)
+	if err != nil {
+		return err
+	}
+	if problems := fill(root, &sources{}, false); len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+func structValue(dst any) (reflect.Value, error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("config: destination must be a non-nil pointer to a struct, got %T", dst)
+	}
+	return v.Elem(), nil
+}
+
+// sources holds the layers Load reads from, highest precedence first.
+type sources struct {
+	lookup   func(string) (string, bool)
+	envFiles map[string]string
+	files    map[string]string
+	credDir  string
+}
+
+// env returns key from the process environment only.
+func (s *sources) env(key string) string {
+	if s.lookup == nil {
+		return ""
+	}
+	if v, ok := s.lookup(key); ok {
+		return v
+	}
+	return ""
+}
+
+// value returns key from the first layer that sets it.
+func (s *sources) value(key string) string {
+	if v := s.env(key); v != "" {
+		return v
+	}
+	if v := s.envFiles[key]; v != "" {
+		return v
+	}
+	return s.files[key]
+}
+
+// fill sets every tagged field of v and, when validate is set, checks
+// required fields and runs validators. It returns the problems found instead
+// of stopping at the first one.
+func fill(v reflect.Value, src *sources, validate bool) []string {
+	var problems, nested []string
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		key := field.Tag.Get("env")
+
+		if key == "" {
+			if isNested(field.Type) {
+				nested = append(nested, fill(fv, src, validate)...)
+			} else if def, ok := field.Tag.Lookup("default"); ok {
+				// Untagged tunables can still carry a default
+				if err := setValue(fv, def); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: invalid default %q: %v", field.Name, def, err))
+				}
+			}
+			continue
+		}
+
+		raw := src.value(key)
+		if field.Tag.Get("secret") == "true" {
+			secret, err := src.resolveSecret(key)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+				continue
+			}
+			raw = secret
+		}
+		if raw == "" {
+			raw = field.Tag.Get("default")
+		}
+		if raw == "" {
+			if validate && field.Tag.Get("required") == "true" {
+				problems = append(problems, fmt.Sprintf("%s: required but not set", key))
+			}
+			continue
+		}
+		if err := setValue(fv, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	// A validator only sees values that parsed; problems in nested structs
+	// do not stop it so that unrelated mistakes are reported together.
+	if validator, ok := v.Addr().Interface().(Validator); ok && validate && len(problems) == 0 {
+		if err := validator.Validate(); err != nil {
+			problems = append(problems, splitErrors(err)...)
+		}
+	}
+	return append(nested, problems...)
+}
+
+func splitErrors(err error) []string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []string
+		for _, e := range joined.Unwrap() {
+			out = append(out, splitErrors(e)...)
+		}
+		return out
+	}
+	return []string{err.Error()}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func isNested(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
+
+func setValue(fv reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", raw)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func readFiles(paths []string) (map[string]string, []string) {
+	values := make(map[string]string)
+	var problems []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		var doc map[string]any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		flatten("", doc, values)
+	}
+	return values, problems
+}
+
+// flatten turns {db: {host: x}} into DB_HOST=x.
+func flatten(prefix string, doc map[string]any, out map[string]string) {
+	for k, v := range doc {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch val := v.(type) {
+		case map[string]any:
+			flatten(key, val, out)
+		case []any:
+			items := make([]string, len(val))
+			for i, item := range val {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+		default:
+			out[key] = fmt.Sprint(val)
+		}
+	}
+}
+
+func readEnvFiles(paths []string) (map[string]string, []string) {
+	values := make(map[string]string)
+	var problems []string
+	for _, path := range paths {
+		fileValues, err := godotenv.Read(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		for k, v := range fileValues {
+			if _, seen := values[k]; !seen {
+				values[k] = v
+			}
+		}
+	}
+	return values, problems
+}
+
+// MaskedValue replaces non-empty secrets in Entries and Fprint.
+const MaskedValue = "********"
+
+// Entry is one effective setting.
+type Entry struct {
+	Key    string
+	Value  string
+	Secret bool
+}
+
+// Entries returns the effective settings of cfg (a struct or pointer to one)
+// in field order, with secrets masked.
+func Entries(cfg any) []Entry {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	var entries []Entry
+	collect(v, &entries)
+	return entries
+}
+
+func collect(v reflect.Value, entries *[]Entry) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		key := field.Tag.Get("env")
+		if key == "" {
+			if isNested(field.Type) {
+				collect(fv, entries)
+			}
+			continue
+		}
+
+		value := formatValue(fv)
+		secret := field.Tag.Get("secret") == "true"
+		if secret && value != "" {
+			value = MaskedValue
+		}
+		*entries = append(*entries, Entry{Key: key, Value: value, Secret: secret})
+	}
+}
+
+func formatValue(fv reflect.Value) string {
+	if fv.Type() == durationType {
+		return time.Duration(fv.Int()).String()
+	}
+	if fv.Kind() == reflect.Slice {
+		items := make([]string, fv.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(fv.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(fv.Interface())
+}
+
+// Fprint writes the effective configuration as KEY=value lines.
+func Fprint(w io.Writer, cfg any) error {
+	for _, e := range Entries(cfg) {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogValue returns the effective configuration as a slog group, so it can
+// be logged as one attribute and still pass through the redaction handler.
+func LogValue(cfg any) slog.Value {
+	entries := Entries(cfg)
+	attrs := make([]slog.Attr, len(entries))
+	for i, e := range entries {
+		attrs[i] = slog.String(e.Key, e.Value)
+	}
+	return slog.GroupValue(attrs...)
+}
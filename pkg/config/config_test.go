@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dbSettings struct {
+	Host     string `env:"DB_HOST" required:"true"`
+	Port     int    `env:"DB_PORT" default:"5432"`
+	Password string `env:"DB_PASSWORD" secret:"true"`
+}
+
+type testConfig struct {
+	Name    string        `env:"NAME" default:"svc"`
+	Debug   bool          `env:"DEBUG"`
+	Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+	Tags    []string      `env:"TAGS"`
+	Backoff time.Duration `default:"1s"`
+	DB      dbSettings
+}
+
+func (c *testConfig) Validate() error {
+	if c.Timeout <= 0 {
+		return errors.New("TIMEOUT: must be positive")
+	}
+	return nil
+}
+
+func envMap(m map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Layers(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", "NAME: from-yaml\ndb:\n  host: yaml-host\n  port: 6000\ntags: [a, b]\n")
+	envFile := writeFile(t, ".env", "DB_PORT=7000\nDB_PASSWORD=hunter2\n")
+
+	var cfg testConfig
+	err := Load(&cfg, Options{
+		Files:     []string{yamlFile, filepath.Join(t.TempDir(), "missing.yaml")},
+		EnvFiles:  []string{envFile},
+		LookupEnv: envMap(map[string]string{"DB_PORT": "8000", "DEBUG": "true", "NAME": ""}),
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		got      any
+		expected any
+	}{
+		{"yaml wins over default", cfg.Name, "from-yaml"},
+		{"nested yaml keys flatten", cfg.DB.Host, "yaml-host"},
+		{"env wins over .env and yaml", cfg.DB.Port, 8000},
+		{".env wins over default", cfg.DB.Password, "hunter2"},
+		{"bool from env", cfg.Debug, true},
+		{"duration default", cfg.Timeout, 5 * time.Second},
+		{"untagged default", cfg.Backoff, time.Second},
+		{"yaml list", strings.Join(cfg.Tags, ","), "a,b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, tt.got)
+			}
+		})
+	}
+}
+
+func TestLoad_ReportsAllProblems(t *testing.T) {
+	var cfg testConfig
+	err := Load(&cfg, Options{
+		LookupEnv: envMap(map[string]string{"DEBUG": "maybe", "TIMEOUT": "soon", "DB_PORT": "x"}),
+	})
+
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	for _, key := range []string{"DEBUG", "TIMEOUT", "DB_PORT", "DB_HOST"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected problem for %s in:\n%v", key, err)
+		}
+	}
+}
+
+func TestLoad_RunsValidators(t *testing.T) {
+	var cfg testConfig
+	err := Load(&cfg, Options{
+		LookupEnv: envMap(map[string]string{"DB_HOST": "localhost", "TIMEOUT": "-1s"}),
+	})
+	if err == nil || !strings.Contains(err.Error(), "must be positive") {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
+
+func TestLoad_RejectsNonPointer(t *testing.T) {
+	if err := Load(testConfig{}, Options{}); err == nil {
+		t.Error("expected error for non-pointer destination, got nil")
+	}
+}
+
+func TestFprint_MasksSecrets(t *testing.T) {
+	cfg := testConfig{Name: "svc", DB: dbSettings{Host: "localhost", Port: 5432, Password: "hunter2"}}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	printed := out.String()
+	if strings.Contains(printed, "hunter2") {
+		t.Errorf("secret leaked in output:\n%s", printed)
+	}
+	if !strings.Contains(printed, "DB_PASSWORD="+MaskedValue) || !strings.Contains(printed, "DB_HOST=localhost") {
+		t.Errorf("unexpected output:\n%s", printed)
+	}
+	for _, a := range LogValue(cfg).Group() {
+		if a.Key == "DB_PASSWORD" && a.Value.String() != MaskedValue {
+			t.Errorf("expected DB_PASSWORD masked in log value, got %s", a.Value)
+		}
+	}
+}
+
+func TestLoadService(t *testing.T) {
+	yamlFile := writeFile(t, "service.yaml", "name: from-yaml\ntimeout: 2s\ndb:\n  host: yaml-host\n")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("NAME=from-env-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	t.Setenv("CONFIG_FILE", yamlFile)
+	t.Setenv("NAME", "")
+	t.Setenv("TIMEOUT", "")
+	t.Setenv("DB_HOST", "")
+
+	var cfg testConfig
+	if err := LoadService(&cfg); err != nil {
+		t.Fatalf("LoadService() error = %v", err)
+	}
+	if cfg.Name != "from-env-file" || cfg.Timeout != 2*time.Second || cfg.DB.Host != "yaml-host" {
+		t.Errorf("expected CONFIG_FILE and .env to be applied, got %+v", cfg)
+	}
+}
@@ -0,0 +1,8 @@
+module config
+
+go 1.25.2
+
+require (
+	github.com/joho/godotenv v1.5.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveSecret looks a secret up so it can stay out of the environment and
+// .env. Highest precedence first:
+//
+//   - KEY in the process environment
+//   - the file named by KEY_FILE (from any layer), as used by Docker secrets
+//   - $CREDENTIALS_DIRECTORY/KEY, then the lowercase name, as written by
+//     systemd's LoadCredential=
+//   - KEY in .env or the YAML file
+//
+// Setting both KEY and KEY_FILE in the environment is an error. Trailing
+// newlines are trimmed from file contents.
+func (s *sources) resolveSecret(key string) (string, error) {
+	direct := s.env(key)
+	path := s.value(key + "_FILE")
+	if direct != "" && s.env(key+"_FILE") != "" {
+		return "", fmt.Errorf("set only one of %s and %s_FILE", key, key)
+	}
+	if direct != "" {
+		return direct, nil
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read %s_FILE: %w", key, err)
+		}
+		return trimSecret(data), nil
+	}
+
+	if s.credDir != "" {
+		for _, name := range []string{key, strings.ToLower(key)} {
+			data, err := os.ReadFile(filepath.Join(s.credDir, name))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return "", fmt.Errorf("read credential %s: %w", name, err)
+			}
+			return trimSecret(data), nil
+		}
+	}
+
+	return s.value(key), nil
+}
+
+func trimSecret(data []byte) string {
+	return strings.TrimRight(string(data), "\r\n")
+}
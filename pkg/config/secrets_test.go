@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type secretConfig struct {
+	Password string `env:"DB_PASSWORD" secret:"true"`
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password.txt")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	credDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(credDir, "db_password"), []byte("from-credential\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	envFile := writeFile(t, ".env", "DB_PASSWORD=from-dotenv\n")
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		credDir     string
+		expected    string
+		expectError string
+	}{
+		{
+			name:     "env wins",
+			env:      map[string]string{"DB_PASSWORD": "from-env"},
+			credDir:  credDir,
+			expected: "from-env",
+		},
+		{
+			name:     "_FILE variant",
+			env:      map[string]string{"DB_PASSWORD_FILE": passwordFile},
+			credDir:  credDir,
+			expected: "from-file",
+		},
+		{
+			name:     "systemd credential (lowercase name)",
+			env:      map[string]string{},
+			credDir:  credDir,
+			expected: "from-credential",
+		},
+		{
+			name:     "credentials directory from environment",
+			env:      map[string]string{"CREDENTIALS_DIRECTORY": credDir},
+			expected: "from-credential",
+		},
+		{
+			name:     "falls back to .env",
+			env:      map[string]string{},
+			expected: "from-dotenv",
+		},
+		{
+			name:        "both env and _FILE",
+			env:         map[string]string{"DB_PASSWORD": "x", "DB_PASSWORD_FILE": passwordFile},
+			expectError: "set only one",
+		},
+		{
+			name:        "unreadable _FILE",
+			env:         map[string]string{"DB_PASSWORD_FILE": filepath.Join(dir, "missing")},
+			expectError: "DB_PASSWORD_FILE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg secretConfig
+			err := Load(&cfg, Options{
+				EnvFiles:       []string{envFile},
+				LookupEnv:      envMap(tt.env),
+				CredentialsDir: tt.credDir,
+			})
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.Password != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, cfg.Password)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RetryConfig controls how long the Open* constructors keep trying to reach
+// a database that is not up yet (e.g. the proxy booting a few seconds before
+// the Postgres container).
+type RetryConfig struct {
+	Timeout        time.Duration `env:"DB_CONNECT_TIMEOUT" default:"30s"` // overall deadline for all attempts
+	AttemptTimeout time.Duration `default:"5s"`                           // deadline for a single ping
+	InitialBackoff time.Duration `default:"500ms"`                        // delay after the first failure, doubled each time
+	MaxBackoff     time.Duration `default:"5s"`                           // cap on the delay between attempts
+}
+
+// PoolConfig tunes connection pools. Zero values keep the driver defaults.
+type PoolConfig struct {
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" default:"10"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" default:"5"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+}
+
+// Validate rejects negative pool settings.
+func (c *PoolConfig) Validate() error {
+	var errs []error
+	if c.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("DB_MAX_OPEN_CONNS: must not be negative"))
+	}
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("DB_MAX_IDLE_CONNS: must not be negative"))
+	}
+	if c.ConnMaxLifetime < 0 {
+		errs = append(errs, fmt.Errorf("DB_CONN_MAX_LIFETIME: must not be negative"))
+	}
+	if c.ConnMaxIdleTime < 0 {
+		errs = append(errs, fmt.Errorf("DB_CONN_MAX_IDLE_TIME: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// ConnectConfig bundles connection, retry and pool settings for the Open*
+// constructors. Services embed it in their own config so it is loaded and
+// validated with everything else.
+type ConnectConfig struct {
+	Postgres PostgresConfig
+	Mongo    MongoConfig
+	Retry    RetryConfig
+	Pool     PoolConfig
+}
+
+// DefaultConnectConfig returns the retry and pool settings used when nothing
+// is configured. Connection details are left empty.
+func DefaultConnectConfig() ConnectConfig {
+	var cfg ConnectConfig
+	if err := config.Defaults(&cfg); err != nil {
+		panic(err) // the defaults above are constants
+	}
+	return cfg
+}
+
+// ConnectConfigFromEnv loads ConnectConfig from the environment on top of the
+// defaults. Invalid or missing values are reported in the error.
+func ConnectConfigFromEnv() (ConnectConfig, error) {
+	var cfg ConnectConfig
+	err := config.Load(&cfg, config.Options{})
+	return cfg, err
+}
+
+// Retry calls fn until it succeeds, cfg.Timeout elapses or ctx is done,
+// sleeping with exponential backoff between attempts. Each call gets its own
+// context bounded by cfg.AttemptTimeout. The last error is returned.
+func Retry(ctx context.Context, cfg RetryConfig, name string, fn func(ctx context.Context) error) error {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	backoff := cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := attemptContext(ctx, cfg.AttemptTimeout)
+		err := fn(attemptCtx)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				slog.InfoContext(ctx, "db_connect_recovered", "database", name, "attempts", attempt)
+			}
+			return nil
+		}
+
+		deadline, hasDeadline := ctx.Deadline()
+		if ctx.Err() != nil || (hasDeadline && time.Until(deadline) < backoff) {
+			return fmt.Errorf("%s: giving up after %d attempts: %w", name, attempt, err)
+		}
+
+		slog.WarnContext(ctx, "db_connect_retry", "database", name, "attempt", attempt, "next_in", backoff.String(), "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%s: giving up after %d attempts: %w", name, attempt, err)
+		}
+
+		backoff *= 2
+		if cfg.MaxBackoff > 0 && backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
+
+func attemptContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// OpenPostgres opens a database/sql pool for driverName (e.g. "postgres" for
+// lib/pq), tunes it and pings it until it answers or cfg.Retry gives up.
+// The caller is responsible for importing the driver.
+func OpenPostgres(ctx context.Context, driverName string, cfg ConnectConfig) (*sql.DB, error) {
+	dsn, err := cfg.Postgres.DSN()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+
+	if err := Retry(ctx, cfg.Retry, "postgres", sqlDB.PingContext); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return sqlDB, nil
+}
+
+// OpenPgxPool creates a pgx connection pool and pings it until it answers or
+// cfg.Retry gives up.
+func OpenPgxPool(ctx context.Context, cfg ConnectConfig) (*pgxpool.Pool, error) {
+	dsn, err := cfg.Postgres.DSN()
+	if err != nil {
+		return nil, err
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+	if cfg.Pool.MaxOpenConns > 0 {
+		poolCfg.MaxConns = int32(cfg.Pool.MaxOpenConns)
+	}
+	if cfg.Pool.ConnMaxLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.Pool.ConnMaxLifetime
+	}
+	if cfg.Pool.ConnMaxIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = cfg.Pool.ConnMaxIdleTime
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+
+	if err := Retry(ctx, cfg.Retry, "postgres", pool.Ping); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// OpenMongo connects to cfg.Mongo.URI and pings the deployment until it answers
+// or cfg.Retry gives up.
+func OpenMongo(ctx context.Context, cfg ConnectConfig) (*mongo.Client, error) {
+	if cfg.Mongo.URI == "" {
+		return nil, errMissingMongoURI
+	}
+
+	opts := options.Client().ApplyURI(cfg.Mongo.URI)
+	if cfg.Pool.MaxOpenConns > 0 {
+		opts.SetMaxPoolSize(uint64(cfg.Pool.MaxOpenConns))
+	}
+	if cfg.Pool.ConnMaxIdleTime > 0 {
+		opts.SetMaxConnIdleTime(cfg.Pool.ConnMaxIdleTime)
+	}
+	if cfg.Retry.AttemptTimeout > 0 {
+		opts.SetServerSelectionTimeout(cfg.Retry.AttemptTimeout)
+	}
+
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: %w", err)
+	}
+
+	ping := func(ctx context.Context) error { return client.Ping(ctx, nil) }
+	if err := Retry(ctx, cfg.Retry, "mongodb", ping); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	return client, nil
+}
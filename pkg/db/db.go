@@ -1,56 +1,83 @@
 package db
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"strings"
+
+	"config"
 )
 
-func GetMongoURI() (string, error) {
-	uri := os.Getenv("MONGO_URI")
-	if uri == "" {
-		return "", fmt.Errorf("missing required environment variable: MONGO_URI")
-	}
-	return uri, nil
+// PostgresConfig holds the Postgres connection settings. DATABASE_URL takes
+// precedence over the individual DB_* variables. The secret fields can also
+// come from *_FILE or a systemd credential (see pkg/config).
+type PostgresConfig struct {
+	URL      string `env:"DATABASE_URL" secret:"true"`
+	Host     string `env:"DB_HOST"`
+	Port     string `env:"DB_PORT" default:"5432"`
+	User     string `env:"DB_USER"`
+	Password string `env:"SERVER_DB_PASSWORD" secret:"true"`
+	Name     string `env:"DB_NAME"`
 }
 
-func GetPostgresDSN() (string, error) {
-	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
-		return dsn, nil
-	}
-
-	host := getEnv("DB_HOST", "")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "")
-	password := os.Getenv("SERVER_DB_PASSWORD")
-	dbname := getEnv("DB_NAME", "")
-
-	if host == "" || user == "" || dbname == "" || password == "" {
-		var missing []string
-		if host == "" {
-			missing = append(missing, "DB_HOST")
-		}
-		if user == "" {
-			missing = append(missing, "DB_USER")
-		}
-		if dbname == "" {
-			missing = append(missing, "DB_NAME")
-		}
-		if password == "" {
-			missing = append(missing, "SERVER_DB_PASSWORD")
-		}
+// Validate reports the variables missing to build a DSN.
+func (c *PostgresConfig) Validate() error {
+	_, err := c.DSN()
+	return err
+}
+
+// DSN returns the connection string for lib/pq or pgx.
+func (c PostgresConfig) DSN() (string, error) {
+	if c.URL != "" {
+		return c.URL, nil
+	}
+
+	var missing []string
+	if c.Host == "" {
+		missing = append(missing, "DB_HOST")
+	}
+	if c.User == "" {
+		missing = append(missing, "DB_USER")
+	}
+	if c.Name == "" {
+		missing = append(missing, "DB_NAME")
+	}
+	if c.Password == "" {
+		missing = append(missing, "SERVER_DB_PASSWORD")
+	}
+	if len(missing) > 0 {
 		return "", fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
 	}
 
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable timezone=UTC",
-		host, port, user, password, dbname,
+		c.Host, c.Port, c.User, c.Password, c.Name,
 	), nil
 }
 
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// MongoConfig holds the MongoDB connection settings. The URI is optional
+// here because not every service talks to MongoDB; OpenMongo checks it.
+type MongoConfig struct {
+	URI string `env:"MONGO_URI" secret:"true"`
+}
+
+var errMissingMongoURI = errors.New("missing required environment variable: MONGO_URI")
+
+func GetMongoURI() (string, error) {
+	var cfg MongoConfig
+	if err := config.Load(&cfg, config.Options{}); err != nil {
+		return "", err
+	}
+	if cfg.URI == "" {
+		return "", errMissingMongoURI
+	}
+	return cfg.URI, nil
+}
+
+func GetPostgresDSN() (string, error) {
+	var cfg PostgresConfig
+	if err := config.Load(&cfg, config.Options{}); err != nil {
+		return "", err
 	}
-	return fallback
+	return cfg.DSN()
 }
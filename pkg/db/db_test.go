@@ -2,6 +2,8 @@ package db
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -50,3 +52,24 @@ func TestGetPostgresDSN_Defaults(t *testing.T) {
 		t.Errorf("Expected DSN %q, got %q", expected, dsn)
 	}
 }
+
+func TestGetPostgresDSN_PasswordFromCredential(t *testing.T) {
+	credDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(credDir, "server_db_password"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "postgres")
+	t.Setenv("DB_NAME", "mydb")
+	t.Setenv("SERVER_DB_PASSWORD", "")
+	t.Setenv("CREDENTIALS_DIRECTORY", credDir)
+
+	dsn, err := GetPostgresDSN()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(dsn, "password=s3cret ") {
+		t.Errorf("expected password from credentials directory, got %q", dsn)
+	}
+}
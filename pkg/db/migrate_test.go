@@ -0,0 +1,191 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		expected    []int64
+		expectError bool
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"m/0002_add_index.sql":    {Data: []byte("CREATE INDEX ...")},
+				"m/0001_create_table.sql": {Data: []byte("CREATE TABLE ...")},
+				"m/README.md":             {Data: []byte("ignored")},
+			},
+			expected: []int64{1, 2},
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"m/0001_a.sql": {Data: []byte("SELECT 1")},
+				"m/001_b.sql":  {Data: []byte("SELECT 1")},
+			},
+			expectError: true,
+		},
+		{
+			name: "missing version prefix",
+			files: fstest.MapFS{
+				"m/create_table.sql": {Data: []byte("SELECT 1")},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(tt.files, "m")
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(migrations) != len(tt.expected) {
+				t.Fatalf("expected %d migrations, got %d", len(tt.expected), len(migrations))
+			}
+			for i, v := range tt.expected {
+				if migrations[i].Version != v {
+					t.Errorf("migration %d: expected version %d, got %d", i, v, migrations[i].Version)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("embedded migrations failed to load: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("expected contiguous versions, got %d at position %d", m.Version, i)
+		}
+	}
+}
+
+func TestMigratorUp(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer sqlDB.Close()
+
+	m := &Migrator{
+		DB: sqlDB,
+		Migrations: []Migration{
+			{Version: 1, Name: "create_table", SQL: "CREATE TABLE widgets (id INT)"},
+			{Version: 2, Name: "add_index", SQL: "CREATE INDEX widgets_idx ON widgets (id)"},
+		},
+	}
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	// Only version 2 is pending
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE INDEX widgets_idx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(int64(2), "add_index").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ran, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(ran) != 1 || ran[0].Version != 2 {
+		t.Errorf("expected only migration 2 to run, got %v", ran)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMigratorUp_RollsBackFailedMigration(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer sqlDB.Close()
+
+	m := &Migrator{
+		DB:         sqlDB,
+		Migrations: []Migration{{Version: 1, Name: "broken", SQL: "CREATE TABLE"}},
+	}
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE").WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = m.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "0001_broken") {
+		t.Errorf("expected error naming the migration, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRunMigrateCommand_Status(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer sqlDB.Close()
+
+	appliedAt := time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+
+	var out bytes.Buffer
+	if err := RunMigrateCommand(context.Background(), sqlDB, "status", &out); err != nil {
+		t.Fatalf("RunMigrateCommand() error = %v", err)
+	}
+
+	report := out.String()
+	if !strings.Contains(report, "2026-01-10T08:00:00Z") {
+		t.Errorf("expected applied time in report, got:\n%s", report)
+	}
+	if !strings.Contains(report, "pending") {
+		t.Errorf("expected pending migrations in report, got:\n%s", report)
+	}
+}
+
+func TestRunMigrateCommand_Unknown(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer sqlDB.Close()
+
+	if err := RunMigrateCommand(context.Background(), sqlDB, "down", &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unknown command, got nil")
+	}
+}
@@ -0,0 +1,18 @@
+-- Host telemetry written by system-metrics (previously ensureSchema).
+CREATE TABLE IF NOT EXISTS system_metrics (
+	time TIMESTAMPTZ(0) NOT NULL,
+	host TEXT NOT NULL,
+	os TEXT NOT NULL,
+	metric_type TEXT NOT NULL,
+	payload JSONB NOT NULL
+);
+
+-- Enable the hypertable only when TimescaleDB is installed, so plain
+-- Postgres (CI, local dev) can still run the migration.
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+		PERFORM create_hypertable('system_metrics', 'time', if_not_exists => true);
+	END IF;
+END
+$$;
@@ -0,0 +1,12 @@
+-- Reading events synced from MongoDB by the proxy (previously
+-- ensureReadingAnalyticsTable, run on every sync request).
+CREATE TABLE IF NOT EXISTS reading_analytics (
+	id SERIAL PRIMARY KEY,
+	mongo_id TEXT UNIQUE NOT NULL,
+	event_timestamp TIMESTAMPTZ,
+	source TEXT,
+	event_type TEXT,
+	payload JSONB,
+	meta JSONB,
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
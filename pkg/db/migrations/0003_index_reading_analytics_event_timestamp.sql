@@ -0,0 +1,3 @@
+-- Grafana panels and range queries filter on event_timestamp.
+CREATE INDEX IF NOT EXISTS reading_analytics_event_timestamp_idx
+	ON reading_analytics (event_timestamp DESC);
@@ -0,0 +1,20 @@
+-- One row per ETL run (written by the proxy when a sync job finishes), so
+-- failed and empty runs are visible in Grafana, not only in the logs.
+CREATE TABLE IF NOT EXISTS etl_runs (
+	id BIGSERIAL PRIMARY KEY,
+	job_id TEXT UNIQUE NOT NULL,
+	pipeline TEXT NOT NULL,
+	trigger TEXT NOT NULL,
+	status TEXT NOT NULL,
+	started_at TIMESTAMPTZ NOT NULL,
+	finished_at TIMESTAMPTZ NOT NULL,
+	duration_ms BIGINT NOT NULL,
+	fetched INTEGER NOT NULL DEFAULT 0,
+	inserted INTEGER NOT NULL DEFAULT 0,
+	skipped INTEGER NOT NULL DEFAULT 0,
+	failed INTEGER NOT NULL DEFAULT 0,
+	error_summary TEXT
+);
+
+CREATE INDEX IF NOT EXISTS etl_runs_started_at_idx
+	ON etl_runs (started_at DESC);
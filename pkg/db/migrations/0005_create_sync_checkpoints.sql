@@ -0,0 +1,7 @@
+-- Where each streaming sync left off: the MongoDB change stream resume
+-- token, saved after every processed event so a restart resumes there.
+CREATE TABLE IF NOT EXISTS sync_checkpoints (
+	pipeline TEXT PRIMARY KEY,
+	resume_token BYTEA NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestContextHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected map[string]string
+		absent   []string
+	}{
+		{
+			name:   "no ids in context",
+			ctx:    context.Background(),
+			absent: []string{"request_id", "trace_id", "span_id"},
+		},
+		{
+			name:     "request id only",
+			ctx:      WithRequestID(context.Background(), "req-123"),
+			expected: map[string]string{"request_id": "req-123"},
+			absent:   []string{"trace_id", "span_id"},
+		},
+		{
+			name: "request and trace ids",
+			ctx: WithTrace(
+				WithRequestID(context.Background(), "req-456"),
+				"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7",
+			),
+			expected: map[string]string{
+				"request_id": "req-456",
+				"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+				"span_id":    "00f067aa0ba902b7",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil))).With("service", "test")
+
+			log.InfoContext(tt.ctx, "sync_started")
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("could not unmarshal log record: %v", err)
+			}
+			for key, want := range tt.expected {
+				if record[key] != want {
+					t.Errorf("%s = %v, want %v", key, record[key], want)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := record[key]; ok {
+					t.Errorf("unexpected %s in record: %v", key, record)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	a, b := NewRequestID(), NewRequestID()
+	if len(a) != 32 {
+		t.Errorf("expected 32 hex characters, got %q", a)
+	}
+	if a == b {
+		t.Errorf("expected unique request ids, got %q twice", a)
+	}
+}
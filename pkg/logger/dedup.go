@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupConfig configures a DedupHandler.
+type DedupConfig struct {
+	// Window is how long identical records are suppressed after the first one.
+	Window time.Duration
+	// Burst is how many identical records are let through per window (1).
+	Burst int
+	// StatePath optionally persists the counters to a JSON file, so one-shot
+	// services (e.g. system-metrics, started every minute by a timer) share a
+	// window across runs.
+	StatePath string
+}
+
+// maxDedupEntries bounds the in-memory map before expired windows are pruned.
+const maxDedupEntries = 256
+
+type dedupEntry struct {
+	Level       slog.Level `json:"level"`
+	Msg         string     `json:"msg"`
+	WindowStart time.Time  `json:"window_start"`
+	Count       int        `json:"count"`
+	Suppressed  int        `json:"suppressed"`
+}
+
+type dedupState struct {
+	mu      sync.Mutex
+	cfg     DedupConfig
+	entries map[string]*dedupEntry
+	now     func() time.Time
+}
+
+// DedupHandler drops records identical to one already written within the
+// configured window. Records are identical when their level, message and
+// attributes (excluding the time) match. The next record let through after
+// a suppression carries a "suppressed_count" attribute. A record that does
+// not come back gets a "log_suppressed" summary once its window has expired
+// and another record is handled, and Flush emits one for anything still
+// pending.
+type DedupHandler struct {
+	next   slog.Handler
+	state  *dedupState
+	prefix string
+}
+
+// NewDedupHandler wraps next. When cfg.StatePath is set, existing counters are
+// loaded from it; an unreadable file simply starts a fresh state.
+func NewDedupHandler(next slog.Handler, cfg DedupConfig) *DedupHandler {
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	st := &dedupState{
+		cfg:     cfg,
+		entries: make(map[string]*dedupEntry),
+		now:     time.Now,
+	}
+	if cfg.StatePath != "" {
+		if data, err := os.ReadFile(cfg.StatePath); err == nil {
+			json.Unmarshal(data, &st.entries)
+		}
+	}
+	return &DedupHandler{next: next, state: st}
+}
+
+func (h *DedupHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *DedupHandler) Handle(ctx context.Context, r slog.Record) error {
+	key := h.key(ctx, r)
+
+	st := h.state
+	st.mu.Lock()
+	now := st.now()
+	e, ok := st.entries[key]
+	emit := true
+	suppressed := 0
+	if !ok || now.Sub(e.WindowStart) >= st.cfg.Window {
+		if ok {
+			suppressed = e.Suppressed
+		}
+		st.entries[key] = &dedupEntry{Level: r.Level, Msg: r.Message, WindowStart: now, Count: 1}
+	} else {
+		e.Count++
+		if e.Count > st.cfg.Burst {
+			e.Suppressed++
+			emit = false
+		}
+	}
+	// Summaries for records that stopped recurring, so one-shot services
+	// report them too
+	summaries := st.summaries(now, true)
+	if len(st.entries) > maxDedupEntries || st.cfg.StatePath != "" {
+		st.prune(now)
+	}
+	st.save()
+	st.mu.Unlock()
+
+	if err := h.emit(ctx, summaries); err != nil || !emit {
+		return err
+	}
+	if suppressed > 0 {
+		r = r.Clone()
+		r.AddAttrs(slog.Int("suppressed_count", suppressed))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *DedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.prefix)
+	for _, a := range attrs {
+		fmt.Fprintf(&b, "%s=%v;", a.Key, a.Value.Resolve())
+	}
+	return &DedupHandler{next: h.next.WithAttrs(attrs), state: h.state, prefix: b.String()}
+}
+
+func (h *DedupHandler) WithGroup(name string) slog.Handler {
+	return &DedupHandler{next: h.next.WithGroup(name), state: h.state, prefix: h.prefix + name + "{"}
+}
+
+// Flush writes a "log_suppressed" summary for every record suppressed since
+// it was last let through, then resets those counters.
+func (h *DedupHandler) Flush(ctx context.Context) error {
+	return h.flush(ctx, false)
+}
+
+// flush writes the pending summaries, or with expiredOnly only those whose
+// window has expired.
+func (h *DedupHandler) flush(ctx context.Context, expiredOnly bool) error {
+	st := h.state
+	st.mu.Lock()
+	summaries := st.summaries(st.now(), expiredOnly)
+	st.save()
+	st.mu.Unlock()
+	return h.emit(ctx, summaries)
+}
+
+func (h *DedupHandler) emit(ctx context.Context, summaries []slog.Record) error {
+	var errs []error
+	for _, r := range summaries {
+		if err := h.next.Handle(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("flush suppressed records: %v", errs)
+	}
+	return nil
+}
+
+func (h *DedupHandler) key(ctx context.Context, r slog.Record) string {
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s|%s|%s|%s|", h.prefix, RequestID(ctx), r.Level, r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(sum, "%s=%v;", a.Key, a.Value.Resolve())
+		return true
+	})
+	return hex.EncodeToString(sum.Sum(nil)[:12])
+}
+
+// summaries builds a "log_suppressed" record for each entry with suppressed
+// records, or with expiredOnly each such entry whose window has expired, and
+// resets their counters. Callers must hold st.mu.
+func (st *dedupState) summaries(now time.Time, expiredOnly bool) []slog.Record {
+	var records []slog.Record
+	for _, e := range st.entries {
+		if e.Suppressed == 0 || (expiredOnly && now.Sub(e.WindowStart) < st.cfg.Window) {
+			continue
+		}
+		r := slog.NewRecord(now, e.Level, "log_suppressed", 0)
+		r.AddAttrs(
+			slog.String("original_msg", e.Msg),
+			slog.Int("suppressed_count", e.Suppressed),
+			slog.Time("window_start", e.WindowStart),
+		)
+		records = append(records, r)
+		e.Suppressed = 0
+	}
+	return records
+}
+
+// prune forgets expired windows that have nothing left to report. Callers
+// must hold st.mu.
+func (st *dedupState) prune(now time.Time) {
+	for k, e := range st.entries {
+		if e.Suppressed == 0 && now.Sub(e.WindowStart) >= st.cfg.Window {
+			delete(st.entries, k)
+		}
+	}
+}
+
+// save persists the counters when a state file is configured. Callers must
+// hold st.mu.
+func (st *dedupState) save() {
+	if st.cfg.StatePath == "" {
+		return
+	}
+	data, err := json.Marshal(st.entries)
+	if err != nil {
+		return
+	}
+	tmp := st.cfg.StatePath + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(st.cfg.StatePath), 0o755); err != nil {
+		return
+	}
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return
+	}
+	os.Rename(tmp, st.cfg.StatePath)
+}
@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("could not unmarshal %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestDedupHandler_SuppressesWithinWindow(t *testing.T) {
+	var buf bytes.Buffer
+	clock := &fakeClock{t: time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)}
+	h := NewDedupHandler(slog.NewJSONHandler(&buf, nil), DedupConfig{Window: time.Hour})
+	h.state.now = clock.now
+	log := slog.New(h).With("service", "system-metrics")
+
+	for i := 0; i < 60; i++ {
+		log.Info("metrics_collected", "status", "success")
+		log.Info("metrics_collected", "status", "partial_failure")
+		clock.advance(time.Minute)
+	}
+	log.Info("metrics_collected", "status", "success")
+
+	// partial_failure did not come back, so it gets a summary instead
+	records := decodeLines(t, &buf)
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d: %s", len(records), buf.String())
+	}
+	if summary := records[2]; summary["msg"] != "log_suppressed" || summary["suppressed_count"] != float64(59) {
+		t.Errorf("expected log_suppressed summary with suppressed_count=59, got %v", summary)
+	}
+	last := records[3]
+	if last["status"] != "success" || last["suppressed_count"] != float64(59) {
+		t.Errorf("expected success record with suppressed_count=59, got %v", last)
+	}
+}
+
+func TestDedupHandler_Burst(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewDedupHandler(slog.NewJSONHandler(&buf, nil), DedupConfig{Window: time.Hour, Burst: 3}))
+
+	for i := 0; i < 10; i++ {
+		log.Warn("ETL_WARN: Document missing ObjectID")
+	}
+
+	if got := len(decodeLines(t, &buf)); got != 3 {
+		t.Errorf("expected 3 records, got %d", got)
+	}
+}
+
+func TestDedupHandler_FlushEmitsSummary(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewDedupHandler(slog.NewJSONHandler(&buf, nil), DedupConfig{Window: time.Hour})
+	log := slog.New(h)
+
+	for i := 0; i < 5; i++ {
+		log.Error("db_insert_failed", "metric_type", "cpu")
+	}
+	if err := h.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	records := decodeLines(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("expected original + summary, got %d: %s", len(records), buf.String())
+	}
+	summary := records[1]
+	if summary["msg"] != "log_suppressed" || summary["original_msg"] != "db_insert_failed" ||
+		summary["suppressed_count"] != float64(4) || summary["level"] != "ERROR" {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+}
+
+func TestDedupHandler_StatePersistsAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log-state.json")
+	clock := &fakeClock{t: time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)}
+
+	// Simulate a one-shot service started every minute.
+	var buf bytes.Buffer
+	for i := 0; i < 61; i++ {
+		h := NewDedupHandler(slog.NewJSONHandler(&buf, nil), DedupConfig{Window: time.Hour, StatePath: path})
+		h.state.now = clock.now
+		slog.New(h).Info("metrics_collected", "status", "success")
+		clock.advance(time.Minute)
+	}
+
+	records := decodeLines(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records across runs, got %d: %s", len(records), buf.String())
+	}
+	if records[1]["suppressed_count"] != float64(59) {
+		t.Errorf("expected suppressed_count=59, got %v", records[1])
+	}
+}
+
+func TestDedupHandler_SummaryWhenRecordStops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log-state.json")
+	clock := &fakeClock{t: time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)}
+
+	// A one-shot service fails three runs in a row, then recovers
+	var buf bytes.Buffer
+	for i := 0; i < 61; i++ {
+		h := NewDedupHandler(slog.NewJSONHandler(&buf, nil), DedupConfig{Window: time.Hour, StatePath: path})
+		h.state.now = clock.now
+		if i < 3 {
+			slog.New(h).Error("db_insert_failed", "metric_type", "cpu")
+		} else {
+			slog.New(h).Info("metrics_collected", "run", i)
+		}
+		clock.advance(time.Minute)
+	}
+
+	var summaries []map[string]any
+	for _, rec := range decodeLines(t, &buf) {
+		if rec["msg"] == "log_suppressed" {
+			summaries = append(summaries, rec)
+		}
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 summary, got %d: %s", len(summaries), buf.String())
+	}
+	if summaries[0]["original_msg"] != "db_insert_failed" || summaries[0]["suppressed_count"] != float64(2) ||
+		summaries[0]["level"] != "ERROR" {
+		t.Errorf("unexpected summary: %v", summaries[0])
+	}
+}
@@ -1,22 +1,255 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
+// level is shared by every handler created through Setup, so the verbosity of
+// a running process can be changed with SetLevel without rebuilding the logger.
+var level = new(slog.LevelVar)
+
+// sink and dedup are the optional handlers installed by the last Setup call,
+// kept so Shutdown can flush them.
+var (
+	sinkMu sync.Mutex
+	sink   *LokiSink
+	dedup  *DedupHandler
+)
+
+// Option customizes the logger built by Setup.
+type Option func(*config)
+
+type config struct {
+	level      slog.Level
+	settings   Settings
+	output     io.Writer
+	redact     bool
+	redactKeys []string
+	lokiURL    string
+	dedup      *DedupConfig
+}
+
+// WithLevel sets the default level used when no LOG_LEVEL variable is set.
+func WithLevel(l slog.Level) Option {
+	return func(c *config) {
+		c.level = l
+	}
+}
+
+// WithOutput redirects the JSON output (defaults to stdout).
+func WithOutput(w io.Writer) Option {
+	return func(c *config) {
+		c.output = w
+	}
+}
+
+// WithRedactKeys masks additional attribute keys on top of
+// DefaultSensitiveKeys.
+func WithRedactKeys(keys ...string) Option {
+	return func(c *config) {
+		c.redactKeys = append(c.redactKeys, keys...)
+	}
+}
+
+// WithoutRedaction disables the secret redaction that Setup enables by default.
+func WithoutRedaction() Option {
+	return func(c *config) {
+		c.redact = false
+	}
+}
+
+// WithLoki pushes records straight to the Loki instance at url instead of
+// writing them to stdout. Stdout is still used as the fallback when Loki is
+// unreachable. The LOKI_URL variable enables the same behaviour.
+func WithLoki(url string) Option {
+	return func(c *config) {
+		c.lokiURL = url
+	}
+}
+
+// Settings are the logger variables as loaded by a service's configuration,
+// so they can also come from .env or config.yaml. Empty fields fall back to
+// the process environment. A service config can hold Settings as is; its
+// LOG_LEVEL_<SERVICE> key differs per service, so the service loads it into
+// a field of its own and copies it to ServiceLevel.
+type Settings struct {
+	Level        string `env:"LOG_LEVEL"`
+	ServiceLevel string // LOG_LEVEL_<SERVICE>
+	LokiURL      string `env:"LOKI_URL"`
+}
+
+// WithSettings uses s in place of the LOG_LEVEL, LOG_LEVEL_<SERVICE> and
+// LOKI_URL variables.
+func WithSettings(s Settings) Option {
+	return func(c *config) {
+		c.settings = s
+		if s.LokiURL != "" {
+			c.lokiURL = s.LokiURL
+		}
+	}
+}
+
+// WithDedup suppresses identical records within cfg.Window (see
+// DedupHandler). It replaces ad-hoc "only log at the top of the hour" checks.
+func WithDedup(cfg DedupConfig) Option {
+	return func(c *config) {
+		c.dedup = &cfg
+	}
+}
+
 // Setup initializes the global slog logger to output JSON to stdout.
-// It adds a permanent "service" field to all log entries.
-func Setup(serviceName string) {
-	opts := &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+// It adds a permanent "service" field to all log entries, plus request_id,
+// trace_id and span_id when they are present in the record's context.
+// Secrets are redacted by default (see RedactHandler).
+//
+// The level is resolved in order from LOG_LEVEL_<SERVICE> (e.g.
+// LOG_LEVEL_SYSTEM_METRICS), LOG_LEVEL, and finally the WithLevel option,
+// which defaults to INFO. WithSettings supplies these variables from the
+// service's configuration.
+func Setup(serviceName string, opts ...Option) {
+	cfg := &config{
+		level:  slog.LevelInfo,
+		output: os.Stdout,
+		redact: true,
+	}
+	if url := os.Getenv("LOKI_URL"); url != "" {
+		cfg.lokiURL = url
+	}
+	for _, opt := range opts {
+		opt(cfg)
 	}
 
-	handler := slog.NewJSONHandler(os.Stdout, opts).
+	envKey, envValue, invalid := resolveLevel(serviceName, cfg)
+	level.Set(cfg.level)
+
+	handlerOpts := &slog.HandlerOptions{
+		Level: level,
+	}
+
+	var handler slog.Handler = slog.NewJSONHandler(cfg.output, handlerOpts)
+	var lokiSink *LokiSink
+	if cfg.lokiURL != "" {
+		lokiSink = NewLokiSink(LokiConfig{
+			URL:      cfg.lokiURL,
+			Service:  serviceName,
+			Fallback: cfg.output,
+		})
+		handler = NewLokiHandler(lokiSink, handlerOpts)
+	}
+	// The service field goes below the dedup handler so the summaries it
+	// writes on Shutdown carry it as well
+	handler = NewContextHandler(handler).
 		WithAttrs([]slog.Attr{
 			slog.String("service", serviceName),
 		})
+	var dedupHandler *DedupHandler
+	if cfg.dedup != nil {
+		dedupHandler = NewDedupHandler(handler, *cfg.dedup)
+		handler = dedupHandler
+	}
+	if cfg.redact {
+		handler = NewRedactHandler(handler, cfg.redactKeys...)
+	}
+
+	replaceFlushers(lokiSink, dedupHandler)
 
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
+
+	if invalid {
+		slog.Warn("log_level_invalid", "key", envKey, "value", envValue, "using", cfg.level.String())
+	}
+}
+
+func replaceFlushers(s *LokiSink, d *DedupHandler) {
+	sinkMu.Lock()
+	old := sink
+	sink, dedup = s, d
+	sinkMu.Unlock()
+
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		old.Close(ctx)
+	}
+}
+
+// Shutdown writes pending "log_suppressed" summaries and flushes records
+// still queued for Loki. When the dedup state is persisted for the next run,
+// only summaries whose window has expired are written.
+// Services should defer it in main so the last lines before exit are not
+// lost.
+func Shutdown(ctx context.Context) error {
+	sinkMu.Lock()
+	s, d := sink, dedup
+	sink, dedup = nil, nil
+	sinkMu.Unlock()
+
+	var err error
+	if d != nil {
+		err = d.flush(ctx, d.state.cfg.StatePath != "")
+	}
+	if s != nil {
+		if closeErr := s.Close(ctx); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// resolveLevel applies the environment overrides to cfg.level. It reports the
+// variable it looked at and whether its value could not be parsed.
+func resolveLevel(serviceName string, cfg *config) (string, string, bool) {
+	for _, v := range []struct{ key, value string }{
+		{ServiceLevelEnv(serviceName), cfg.settings.ServiceLevel},
+		{"LOG_LEVEL", cfg.settings.Level},
+	} {
+		key, value := v.key, v.value
+		if value == "" {
+			value = os.Getenv(key)
+		}
+		if value == "" {
+			continue
+		}
+		l, err := ParseLevel(value)
+		if err != nil {
+			return key, value, true
+		}
+		cfg.level = l
+		return key, value, false
+	}
+	return "", "", false
+}
+
+// ServiceLevelEnv returns the per-service level variable, e.g.
+// "system-metrics" becomes "LOG_LEVEL_SYSTEM_METRICS".
+func ServiceLevelEnv(serviceName string) string {
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(serviceName))
+	return "LOG_LEVEL_" + name
+}
+
+// ParseLevel converts names such as "debug", "INFO" or "warn+2" into a level.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q", s)
+	}
+	return l, nil
+}
+
+// Level returns the current level of the global logger.
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the level of the global logger at runtime.
+func SetLevel(l slog.Level) {
+	level.Set(l)
 }
@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetup_LevelResolution(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		opts     []Option
+		expected slog.Level
+	}{
+		{
+			name:     "default is info",
+			expected: slog.LevelInfo,
+		},
+		{
+			name:     "option sets default",
+			opts:     []Option{WithLevel(slog.LevelWarn)},
+			expected: slog.LevelWarn,
+		},
+		{
+			name:     "LOG_LEVEL overrides option",
+			env:      map[string]string{"LOG_LEVEL": "debug"},
+			opts:     []Option{WithLevel(slog.LevelWarn)},
+			expected: slog.LevelDebug,
+		},
+		{
+			name: "service override wins over LOG_LEVEL",
+			env: map[string]string{
+				"LOG_LEVEL":                    "debug",
+				"LOG_LEVEL_TEST_SERVICE":       "error",
+				"LOG_LEVEL_SOME_OTHER_SERVICE": "warn",
+			},
+			expected: slog.LevelError,
+		},
+		{
+			name:     "settings apply without variables",
+			opts:     []Option{WithSettings(Settings{Level: "warn"})},
+			expected: slog.LevelWarn,
+		},
+		{
+			name:     "service setting wins over LOG_LEVEL variable",
+			env:      map[string]string{"LOG_LEVEL": "debug"},
+			opts:     []Option{WithSettings(Settings{ServiceLevel: "error"})},
+			expected: slog.LevelError,
+		},
+		{
+			name:     "invalid value keeps default",
+			env:      map[string]string{"LOG_LEVEL": "loud"},
+			expected: slog.LevelInfo,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LOG_LEVEL", "")
+			t.Setenv("LOG_LEVEL_TEST_SERVICE", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var buf bytes.Buffer
+			Setup("test-service", append(tt.opts, WithOutput(&buf))...)
+
+			if got := Level(); got != tt.expected {
+				t.Errorf("Level() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetLevel_AppliesAtRuntime(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_LEVEL_TEST_SERVICE", "")
+
+	var buf bytes.Buffer
+	Setup("test-service", WithOutput(&buf))
+
+	slog.Debug("hidden_message")
+	if strings.Contains(buf.String(), "hidden_message") {
+		t.Fatalf("debug record written at info level: %s", buf.String())
+	}
+
+	SetLevel(slog.LevelDebug)
+	slog.Debug("visible_message")
+	if !strings.Contains(buf.String(), "visible_message") {
+		t.Errorf("debug record missing after SetLevel: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"service":"test-service"`) {
+		t.Errorf("service field missing: %s", buf.String())
+	}
+}
+
+func TestShutdown_SummaryHasService(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_LEVEL_TEST_SERVICE", "")
+	t.Setenv("LOKI_URL", "")
+
+	var buf bytes.Buffer
+	Setup("test-service", WithOutput(&buf), WithDedup(DedupConfig{Window: time.Hour}))
+	slog.Info("repeated_message")
+	slog.Info("repeated_message")
+	buf.Reset()
+
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "log_suppressed") {
+		t.Fatalf("summary missing: %s", out)
+	}
+	if !strings.Contains(out, `"service":"test-service"`) {
+		t.Errorf("service field missing from summary: %s", out)
+	}
+}
+
+func TestServiceLevelEnv(t *testing.T) {
+	if got := ServiceLevelEnv("system-metrics"); got != "LOG_LEVEL_SYSTEM_METRICS" {
+		t.Errorf("ServiceLevelEnv() = %s, want LOG_LEVEL_SYSTEM_METRICS", got)
+	}
+}
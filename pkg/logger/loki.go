@@ -0,0 +1,369 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LokiConfig configures a LokiSink. Zero values fall back to the defaults
+// noted on each field.
+type LokiConfig struct {
+	URL           string        // Loki base URL, e.g. http://localhost:3100
+	Service       string        // value of the "service" label
+	BatchSize     int           // records per push (100)
+	BufferSize    int           // records queued before overflowing to Fallback (1000)
+	FlushInterval time.Duration // max time a record waits before being pushed (2s)
+	MaxRetries    int           // retries per batch after the first attempt (3; negative disables)
+	MinBackoff    time.Duration // first retry delay, doubled on each retry (250ms)
+	MaxBackoff    time.Duration // retry delay cap and post-failure cool-down (5s)
+	Timeout       time.Duration // per-request timeout (5s)
+	Fallback      io.Writer     // receives records Loki did not accept (stdout)
+	Client        *http.Client
+}
+
+func (c *LokiConfig) setDefaults() {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 1000
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 2 * time.Second
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 250 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 5 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.Fallback == nil {
+		c.Fallback = os.Stdout
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: c.Timeout}
+	}
+}
+
+type lokiEntry struct {
+	time  time.Time
+	level string
+	line  string
+}
+
+// LokiSink batches log lines in a bounded buffer and pushes them to Loki's
+// /loki/api/v1/push endpoint from a background goroutine. Failed pushes are
+// retried with exponential backoff; lines that still cannot be delivered, or
+// that overflow the buffer, are written to the fallback writer instead of
+// being lost.
+type LokiSink struct {
+	cfg      LokiConfig
+	endpoint string
+
+	mu        sync.Mutex
+	entries   []lokiEntry
+	closed    bool
+	downUntil time.Time
+
+	fallbackMu sync.Mutex
+
+	wake    chan struct{}
+	flushCh chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewLokiSink starts a sink pushing to cfg.URL. Call Close to flush it.
+func NewLokiSink(cfg LokiConfig) *LokiSink {
+	cfg.setDefaults()
+	s := &LokiSink{
+		cfg:      cfg,
+		endpoint: strings.TrimRight(cfg.URL, "/") + "/loki/api/v1/push",
+		wake:     make(chan struct{}, 1),
+		flushCh:  make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *LokiSink) enqueue(e lokiEntry) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		s.writeFallback([]lokiEntry{e})
+		return
+	}
+	var overflow []lokiEntry
+	if len(s.entries) >= s.cfg.BufferSize {
+		overflow = []lokiEntry{s.entries[0]}
+		s.entries = s.entries[1:]
+	}
+	s.entries = append(s.entries, e)
+	full := len(s.entries) >= s.cfg.BatchSize
+	s.mu.Unlock()
+
+	if overflow != nil {
+		s.writeFallback(overflow)
+	}
+	if full {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *LokiSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.drain(false)
+		case <-s.wake:
+			s.drain(true)
+		case ack := <-s.flushCh:
+			s.drain(false)
+			close(ack)
+		case <-s.stop:
+			s.drain(false)
+			return
+		}
+	}
+}
+
+// drain pushes queued entries batch by batch. With fullOnly set it stops as
+// soon as less than a full batch remains, leaving the rest for the ticker.
+func (s *LokiSink) drain(fullOnly bool) {
+	for {
+		s.mu.Lock()
+		n := len(s.entries)
+		if n == 0 || (fullOnly && n < s.cfg.BatchSize) {
+			s.mu.Unlock()
+			return
+		}
+		if n > s.cfg.BatchSize {
+			n = s.cfg.BatchSize
+		}
+		batch := make([]lokiEntry, n)
+		copy(batch, s.entries)
+		s.entries = s.entries[n:]
+		s.mu.Unlock()
+
+		s.send(batch)
+	}
+}
+
+func (s *LokiSink) send(batch []lokiEntry) {
+	s.mu.Lock()
+	down := time.Now().Before(s.downUntil)
+	s.mu.Unlock()
+	if down {
+		s.writeFallback(batch)
+		return
+	}
+
+	body, err := encodeLokiPush(s.cfg.Service, batch)
+	if err != nil {
+		s.writeFallback(batch)
+		return
+	}
+
+	backoff := s.cfg.MinBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := s.push(body)
+		if err == nil {
+			return
+		}
+		if !retryable || attempt >= s.cfg.MaxRetries {
+			s.mu.Lock()
+			s.downUntil = time.Now().Add(s.cfg.MaxBackoff)
+			s.mu.Unlock()
+			s.writeFallback(batch)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			// Shutting down: do not hold the process up with more retries.
+			s.writeFallback(batch)
+			return
+		}
+		backoff *= 2
+		if backoff > s.cfg.MaxBackoff {
+			backoff = s.cfg.MaxBackoff
+		}
+	}
+}
+
+// push sends one request and reports whether a failure is worth retrying.
+func (s *LokiSink) push(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("loki push failed: %s", resp.Status)
+}
+
+func (s *LokiSink) writeFallback(entries []lokiEntry) {
+	s.fallbackMu.Lock()
+	defer s.fallbackMu.Unlock()
+	for _, e := range entries {
+		io.WriteString(s.cfg.Fallback, e.line+"\n")
+	}
+}
+
+// Flush blocks until everything queued so far has been pushed (or written
+// to the fallback), or until ctx is done.
+func (s *LokiSink) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case s.flushCh <- ack:
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes the remaining records and stops the background goroutine.
+// Records logged after Close go straight to the fallback writer.
+func (s *LokiSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func encodeLokiPush(service string, batch []lokiEntry) ([]byte, error) {
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, e := range batch {
+		st, ok := streams[e.level]
+		if !ok {
+			st = &lokiStream{Stream: map[string]string{"service": service, "level": e.level}}
+			streams[e.level] = st
+			order = append(order, e.level)
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(e.time.UnixNano(), 10), e.line})
+	}
+
+	payload := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, level := range order {
+		payload.Streams = append(payload.Streams, streams[level])
+	}
+	return json.Marshal(payload)
+}
+
+// LokiHandler formats records as the same JSON lines Setup writes to stdout
+// and queues them on a LokiSink.
+type LokiHandler struct {
+	sink  *LokiSink
+	inner slog.Handler
+	mu    *sync.Mutex
+	buf   *bytes.Buffer
+}
+
+// NewLokiHandler returns a handler writing to sink.
+func NewLokiHandler(sink *LokiSink, opts *slog.HandlerOptions) *LokiHandler {
+	buf := new(bytes.Buffer)
+	return &LokiHandler{
+		sink:  sink,
+		inner: slog.NewJSONHandler(buf, opts),
+		mu:    new(sync.Mutex),
+		buf:   buf,
+	}
+}
+
+func (h *LokiHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+func (h *LokiHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	h.buf.Reset()
+	err := h.inner.Handle(ctx, r)
+	line := strings.TrimSuffix(h.buf.String(), "\n")
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ts := r.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	h.sink.enqueue(lokiEntry{time: ts, level: r.Level.String(), line: line})
+	return nil
+}
+
+func (h *LokiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LokiHandler{sink: h.sink, inner: h.inner.WithAttrs(attrs), mu: h.mu, buf: h.buf}
+}
+
+func (h *LokiHandler) WithGroup(name string) slog.Handler {
+	return &LokiHandler{sink: h.sink, inner: h.inner.WithGroup(name), mu: h.mu, buf: h.buf}
+}
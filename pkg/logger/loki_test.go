@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lokiPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+// fakeLoki records pushes and fails the first `failures` requests.
+type fakeLoki struct {
+	mu       sync.Mutex
+	pushes   []lokiPush
+	requests int
+	failures int
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if r.URL.Path != "/loki/api/v1/push" {
+		http.NotFound(w, r)
+		return
+	}
+	if f.requests <= f.failures {
+		http.Error(w, "ingester unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var p lokiPush
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.pushes = append(f.pushes, p)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeLoki) lines() map[string][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string][]string)
+	for _, p := range f.pushes {
+		for _, st := range p.Streams {
+			key := st.Stream["service"] + "/" + st.Stream["level"]
+			for _, v := range st.Values {
+				out[key] = append(out[key], v[1])
+			}
+		}
+	}
+	return out
+}
+
+func TestLokiHandler_PushesWithLabels(t *testing.T) {
+	loki := &fakeLoki{}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	var fallback bytes.Buffer
+	sink := NewLokiSink(LokiConfig{URL: srv.URL, Service: "proxy", Fallback: &fallback})
+	log := slog.New(NewLokiHandler(sink, nil)).With("service", "proxy")
+
+	log.Info("request_processed", "status", 200)
+	log.Error("ETL_ERROR: Failed to query Mongo")
+
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := loki.lines()
+	if got := lines["proxy/INFO"]; len(got) != 1 || !strings.Contains(got[0], `"msg":"request_processed"`) {
+		t.Errorf("unexpected INFO stream: %v", got)
+	}
+	if got := lines["proxy/ERROR"]; len(got) != 1 || !strings.Contains(got[0], `"service":"proxy"`) {
+		t.Errorf("unexpected ERROR stream: %v", got)
+	}
+	if fallback.Len() != 0 {
+		t.Errorf("expected nothing on fallback, got %s", fallback.String())
+	}
+}
+
+func TestLokiSink_RetriesWithBackoff(t *testing.T) {
+	loki := &fakeLoki{failures: 2}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	var fallback bytes.Buffer
+	sink := NewLokiSink(LokiConfig{
+		URL:        srv.URL,
+		Service:    "proxy",
+		MaxRetries: 3,
+		MinBackoff: time.Millisecond,
+		Fallback:   &fallback,
+	})
+	slog.New(NewLokiHandler(sink, nil)).Info("metrics_collected")
+
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	sink.Close(context.Background())
+
+	if got := loki.lines()["proxy/INFO"]; len(got) != 1 {
+		t.Errorf("expected the record to be delivered after retries, got %v", got)
+	}
+	if fallback.Len() != 0 {
+		t.Errorf("expected nothing on fallback, got %s", fallback.String())
+	}
+}
+
+func TestLokiSink_FallsBackWhenLokiIsDown(t *testing.T) {
+	loki := &fakeLoki{failures: 100}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	var fallback bytes.Buffer
+	sink := NewLokiSink(LokiConfig{
+		URL:        srv.URL,
+		Service:    "proxy",
+		MaxRetries: 2,
+		MinBackoff: time.Millisecond,
+		Fallback:   &fallback,
+	})
+	slog.New(NewLokiHandler(sink, nil)).Warn("ETL_WARN: Failed to update Mongo status")
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	sink.Close(context.Background())
+
+	if !strings.Contains(fallback.String(), "ETL_WARN: Failed to update Mongo status") {
+		t.Errorf("expected record on fallback, got %q", fallback.String())
+	}
+	loki.mu.Lock()
+	defer loki.mu.Unlock()
+	if loki.requests != 3 {
+		t.Errorf("expected 1 attempt + 2 retries, got %d requests", loki.requests)
+	}
+}
+
+func TestLokiSink_BufferOverflowGoesToFallback(t *testing.T) {
+	loki := &fakeLoki{}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	var fallback bytes.Buffer
+	sink := NewLokiSink(LokiConfig{
+		URL:           srv.URL,
+		Service:       "proxy",
+		BatchSize:     100,
+		BufferSize:    2,
+		FlushInterval: time.Hour,
+		Fallback:      &fallback,
+	})
+	log := slog.New(NewLokiHandler(sink, nil))
+	log.Info("first")
+	log.Info("second")
+	log.Info("third")
+	sink.Close(context.Background())
+
+	if !strings.Contains(fallback.String(), `"msg":"first"`) {
+		t.Errorf("expected oldest record on fallback, got %q", fallback.String())
+	}
+	if got := loki.lines()["proxy/INFO"]; len(got) != 2 {
+		t.Errorf("expected the two newest records in Loki, got %v", got)
+	}
+}
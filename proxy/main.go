@@ -1,47 +1,247 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"config"
+	"db"
 	"logger"
 	"proxy/utils"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	// Initialize structured logging first
-	logger.Setup("proxy")
+	migrateCmd := flag.String("migrate", "", "run schema migrations (up|status) and exit")
+	dryRun := flag.Bool("dry-run", false, "preview the reading sync (nothing is written) and exit")
+	spoolDir := flag.String("spool", "", "sync once from an NDJSON spool directory instead of MongoDB and exit (with -dry-run, preview it)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration (secrets masked) and exit")
+	flag.Parse()
 
-	godotenv.Load(".env")
-	godotenv.Load("../.env")
+	// The logger settings are part of the config, so it is loaded before
+	// logging is set up and any problem is reported afterwards
+	cfg, err := utils.LoadConfig()
 
-	dbPostgres := utils.InitPostgres("postgres")
-	mongoClient := utils.InitMongo()
+	logger.Setup("proxy", logger.WithSettings(cfg.LogSettings()))
+	defer logger.Shutdown(context.Background())
+
+	// Migrations and spool imports do not contact MongoDB
+	if err == nil && *migrateCmd == "" && *spoolDir == "" {
+		err = cfg.ValidateMongo()
+	}
+	if err != nil {
+		slog.Error("config_invalid", "error", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		config.Fprint(os.Stdout, cfg)
+		return
+	}
+	slog.Info("config_loaded", "config", config.LogValue(cfg))
+
+	var schemas *utils.SchemaRegistry
+	if cfg.Sync.SchemaDir != "" {
+		schemas, err = utils.LoadSchemaRegistry(cfg.Sync.SchemaDir)
+		if err != nil {
+			slog.Error("schema_registry_invalid", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("schemas_loaded", "dir", cfg.Sync.SchemaDir, "count", schemas.Len())
+	}
+
+	dbPostgres := utils.InitPostgres("postgres", cfg.DB)
+
+	if *migrateCmd != "" {
+		if err := db.RunMigrateCommand(context.Background(), dbPostgres, *migrateCmd, os.Stdout); err != nil {
+			slog.Error("migration_failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// A dry run must not touch either database, so it skips migrations
+	if *dryRun {
+		if err := printDryRun(dbPostgres, schemas, cfg.Sync, cfg.DB, *spoolDir); err != nil {
+			slog.Error("sync_dry_run_failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Bring the shared schema up to date before serving
+	applied, err := db.Migrate(context.Background(), dbPostgres)
+	if err != nil {
+		slog.Error("migration_failed", "error", err)
+		os.Exit(1)
+	}
+	for _, m := range applied {
+		slog.Info("migration_applied", "version", m.Version, "name", m.Name)
+	}
+
+	if *spoolDir != "" {
+		if err := importSpool(dbPostgres, schemas, cfg.Sync, *spoolDir); err != nil {
+			slog.Error("spool_import_failed", "dir", *spoolDir, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	mongoClient := utils.InitMongo(cfg.DB)
 
 	// Initialize the reading service
 	readingService := &utils.ReadingService{
 		DB:          dbPostgres,
 		MongoClient: mongoClient,
+		Sync:        cfg.Sync,
+		Schemas:     schemas,
 	}
 
-	// Determine port
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8085"
+	// With SYNC_SCHEDULE the proxy runs the sync itself; the systemd timer
+	// is then optional
+	var scheduler *utils.Scheduler
+	if cfg.Sync.Schedule != "" {
+		scheduler, err = utils.NewScheduler(readingService)
+		if err != nil {
+			slog.Error("sync_schedule_invalid", "error", err)
+			os.Exit(1)
+		}
 	}
 
 	// Register HTTP handlers with logging middleware
-	http.HandleFunc("/", utils.WithLogging(utils.HomeHandler))
-	http.HandleFunc("/api/reading", utils.WithLogging(readingService.ReadingHandler))
-	http.HandleFunc("/api/sync/reading", utils.WithLogging(readingService.SyncReadingHandler))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", utils.WithLogging(utils.HomeHandler))
+	mux.HandleFunc("/api/reading", utils.WithLogging(readingService.ReadingHandler))
+	mux.HandleFunc("/api/sync/reading", utils.WithLogging(utils.WithAuth(cfg.Sync.Token, readingService.SyncReadingHandler)))
+	mux.HandleFunc("/api/sync/jobs/{id}", utils.WithLogging(utils.WithAuth(cfg.Sync.Token, readingService.SyncJobHandler)))
+	mux.HandleFunc("/api/sync/runs", utils.WithLogging(utils.WithAuth(cfg.Sync.Token, readingService.RunsHandler)))
+	mux.HandleFunc("/api/sync/schedule", utils.WithLogging(utils.WithAuth(cfg.Sync.Token, scheduler.ScheduleHandler)))
+	mux.HandleFunc("/api/sync/failed", utils.WithLogging(utils.WithAuth(cfg.Sync.Token, readingService.FailedHandler)))
+	mux.HandleFunc("/api/sync/failed/requeue", utils.WithLogging(utils.WithAuth(cfg.Sync.Token, readingService.RequeueHandler)))
+	mux.HandleFunc("/admin/log-level", utils.WithLogging(utils.WithAuth(cfg.Sync.Token, utils.LogLevelHandler)))
+
+	// Probes and the metrics scrape are polled often, so they skip the
+	// request log; probe failures are logged by the handler itself
+	mux.HandleFunc("/healthz", utils.HealthzHandler)
+	mux.HandleFunc("/readyz", readingService.ReadyzHandler)
+	mux.Handle("/metrics", utils.MetricsHandler())
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if scheduler != nil {
+		scheduler.Start(ctx)
+	}
+	if cfg.Sync.Stream {
+		readingService.StartStream(ctx)
+	}
 
-	slog.Info("🚀 The GO proxy listening on port", "port", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("🚀 The GO proxy listening on port", "port", cfg.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		slog.Error("Server failed to start", "error", err)
 		os.Exit(1)
+	case <-ctx.Done():
+	}
+	// Restore default signal handling so a second Ctrl-C exits immediately
+	stop()
+
+	shutdown(srv, readingService, cfg.Server.ShutdownTimeout)
+}
+
+// shutdown stops in order: running syncs finish their current document, the
+// server drains in-flight requests, background sync jobs, the scheduler and
+// the change stream return, then MongoDB and Postgres are closed.
+func shutdown(srv *http.Server, readingService *utils.ReadingService, timeout time.Duration) {
+	slog.Info("shutdown_started", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	readingService.Stop()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("http_shutdown_failed", "error", err)
+	}
+	if err := readingService.Wait(ctx); err != nil {
+		slog.Error("sync_jobs_shutdown_failed", "error", err)
+	}
+	if err := readingService.MongoClient.Disconnect(ctx); err != nil {
+		slog.Error("db_close_failed", "database", "mongodb", "error", err)
+	}
+	if err := readingService.DB.Close(); err != nil {
+		slog.Error("db_close_failed", "database", "postgres", "error", err)
+	}
+	slog.Info("shutdown_complete")
+}
+
+// printDryRun previews the reading sync and prints the report as JSON. With
+// spoolDir it previews the spool and does not connect to MongoDB.
+func printDryRun(dbPostgres *sql.DB, schemas *utils.SchemaRegistry, syncCfg utils.SyncConfig, dbCfg db.ConnectConfig, spoolDir string) error {
+	defer dbPostgres.Close()
+
+	readingService := &utils.ReadingService{
+		DB:      dbPostgres,
+		Sync:    syncCfg,
+		Schemas: schemas,
+	}
+	if spoolDir != "" {
+		readingService.Source = &utils.SpoolSource{Dir: spoolDir, MaxAttempts: syncCfg.MaxAttempts}
+	} else {
+		readingService.MongoClient = utils.InitMongo(dbCfg)
+		defer readingService.MongoClient.Disconnect(context.Background())
+	}
+	report, err := readingService.DryRun(context.Background())
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// importSpool runs one sync from the spool directory and prints the finished
+// job as JSON. It fails unless the job succeeded.
+func importSpool(dbPostgres *sql.DB, schemas *utils.SchemaRegistry, syncCfg utils.SyncConfig, dir string) error {
+	defer dbPostgres.Close()
+
+	readingService := &utils.ReadingService{
+		DB:      dbPostgres,
+		Source:  &utils.SpoolSource{Dir: dir, MaxAttempts: syncCfg.MaxAttempts},
+		Sync:    syncCfg,
+		Schemas: schemas,
+	}
+	job, _ := readingService.RunSync(context.Background(), utils.TriggerManual)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(job); err != nil {
+		return err
+	}
+	if job.Status != utils.JobSucceeded {
+		return fmt.Errorf("sync job %s %s", job.ID, job.Status)
 	}
+	return nil
 }
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"logger"
+)
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// LogLevelHandler reports (GET) or changes (PUT/POST) the level of the running
+// process. The new level can be sent as JSON ({"level":"debug"}) or as the
+// "level" query parameter. main wraps it in WithAuth.
+func LogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		value := r.URL.Query().Get("level")
+		if value == "" {
+			var req logLevelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			value = req.Level
+		}
+
+		newLevel, err := logger.ParseLevel(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		oldLevel := logger.Level()
+		logger.SetLevel(newLevel)
+		slog.Warn("log_level_changed", "from", oldLevel.String(), "to", newLevel.String(), "remote_ip", r.RemoteAddr)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": logger.Level().String()})
+}
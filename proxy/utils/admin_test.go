@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"logger"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	defer logger.SetLevel(logger.Level())
+	logger.SetLevel(slog.LevelInfo)
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+		expectedLevel  string
+	}{
+		{
+			name:           "get current level",
+			method:         "GET",
+			target:         "/admin/log-level",
+			expectedStatus: http.StatusOK,
+			expectedLevel:  "INFO",
+		},
+		{
+			name:           "set level from json body",
+			method:         "PUT",
+			target:         "/admin/log-level",
+			body:           `{"level":"debug"}`,
+			expectedStatus: http.StatusOK,
+			expectedLevel:  "DEBUG",
+		},
+		{
+			name:           "set level from query",
+			method:         "POST",
+			target:         "/admin/log-level?level=warn",
+			expectedStatus: http.StatusOK,
+			expectedLevel:  "WARN",
+		},
+		{
+			name:           "reject unknown level",
+			method:         "PUT",
+			target:         "/admin/log-level",
+			body:           `{"level":"loud"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "reject unsupported method",
+			method:         "DELETE",
+			target:         "/admin/log-level",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			LogLevelHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if tt.expectedLevel == "" {
+				return
+			}
+
+			var response map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("could not unmarshal response: %v", err)
+			}
+			if response["level"] != tt.expectedLevel {
+				t.Errorf("unexpected level: got %v want %v", response["level"], tt.expectedLevel)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithAuth(t *testing.T) {
+	const secret = "0123456789abcdef"
+	now := time.Now().Unix()
+	body := `{"batch":1}`
+	const target = "/api/sync/reading?wait=true"
+	largeBody := strings.Repeat("x", maxSignedBody+1)
+
+	tests := []struct {
+		name           string
+		target         string // defaults to target
+		body           string // defaults to body
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{
+			name:           "bearer_valid",
+			headers:        map[string]string{"Authorization": "Bearer " + secret},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "bearer_invalid",
+			headers:        map[string]string{"Authorization": "Bearer wrong"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "missing_credentials",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "hmac_valid",
+			headers: map[string]string{
+				TimestampHeader: strconv.FormatInt(now, 10),
+				SignatureHeader: SignRequest(secret, now, "POST", target, []byte(body)),
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "hmac_query_changed",
+			target: "/api/sync/reading?dry_run=true",
+			headers: map[string]string{
+				TimestampHeader: strconv.FormatInt(now, 10),
+				SignatureHeader: SignRequest(secret, now, "POST", target, []byte(body)),
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "hmac_path_without_query",
+			headers: map[string]string{
+				TimestampHeader: strconv.FormatInt(now, 10),
+				SignatureHeader: SignRequest(secret, now, "POST", "/api/sync/reading", []byte(body)),
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "hmac_body_too_large",
+			body: largeBody,
+			headers: map[string]string{
+				TimestampHeader: strconv.FormatInt(now, 10),
+				SignatureHeader: SignRequest(secret, now, "POST", target, []byte(largeBody[:maxSignedBody])),
+			},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "hmac_bad_signature",
+			headers: map[string]string{
+				TimestampHeader: strconv.FormatInt(now, 10),
+				SignatureHeader: SignRequest("another-secret-value", now, "POST", target, []byte(body)),
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "hmac_stale_timestamp",
+			headers: map[string]string{
+				TimestampHeader: strconv.FormatInt(now-3600, 10),
+				SignatureHeader: SignRequest(secret, now-3600, "POST", target, []byte(body)),
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received string
+			handler := WithAuth(secret, func(w http.ResponseWriter, r *http.Request) {
+				// The signed body must still be readable by the handler
+				b, _ := io.ReadAll(r.Body)
+				received = string(b)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			reqTarget, reqBody := target, body
+			if tt.target != "" {
+				reqTarget = tt.target
+			}
+			if tt.body != "" {
+				reqBody = tt.body
+			}
+			req := httptest.NewRequest("POST", reqTarget, strings.NewReader(reqBody))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusUnauthorized {
+				if w.Header().Get("WWW-Authenticate") == "" {
+					t.Error("expected WWW-Authenticate header on 401")
+				}
+			} else if w.Code == http.StatusOK && received != body {
+				t.Errorf("expected handler to receive body %q, got %q", body, received)
+			}
+		})
+	}
+}
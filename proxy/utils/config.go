@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"config"
+	"db"
+	"logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+// SyncConfig tells the reading sync where to pull documents from.
+type SyncConfig struct {
+	// The Mongo names are only needed when MongoDB is the source; see
+	// Config.ValidateMongo.
+	MongoDBName     string `env:"MONGO_DB_NAME"`
+	MongoCollection string `env:"MONGO_COLLECTION"`
+	BatchSize       int    `env:"BATCH_SIZE" default:"100"`
+	// ChunkSize is how many documents are written per Postgres transaction
+	ChunkSize int `env:"SYNC_CHUNK_SIZE" default:"100"`
+	// A sync keeps fetching pages until the backlog is drained or one of
+	// these budgets runs out. MaxDocuments 0 means no document limit.
+	MaxDuration  time.Duration `env:"SYNC_MAX_DURATION" default:"4m"`
+	MaxDocuments int           `env:"SYNC_MAX_DOCUMENTS" default:"50000"`
+	// MaxAttempts is how many failed syncs a document gets before it is
+	// moved to dead_letter.
+	MaxAttempts int `env:"SYNC_MAX_ATTEMPTS" default:"5"`
+	// SchemaDir holds the payload JSON Schemas; empty disables validation.
+	SchemaDir string `env:"SYNC_SCHEMA_DIR"`
+	// Schedule is a cron expression for running the sync inside the proxy;
+	// empty leaves scheduling to the systemd timer. Each run starts up to
+	// ScheduleJitter late, and with CatchUp a run missed while the proxy was
+	// down is made up at startup.
+	Schedule       string        `env:"SYNC_SCHEDULE"`
+	ScheduleJitter time.Duration `env:"SYNC_SCHEDULE_JITTER" default:"0s"`
+	CatchUp        bool          `env:"SYNC_CATCH_UP" default:"true"`
+	// Stream processes documents as they are inserted, through a MongoDB
+	// change stream. Where change streams are not supported it polls every
+	// StreamPollInterval instead.
+	Stream             bool          `env:"SYNC_STREAM" default:"false"`
+	StreamPollInterval time.Duration `env:"SYNC_STREAM_POLL_INTERVAL" default:"1m"`
+	// Token authenticates sync requests, as a bearer token or HMAC key
+	Token string `env:"SYNC_TOKEN" required:"true" secret:"true"`
+}
+
+func (c *SyncConfig) Validate() error {
+	var errs []error
+	if c.BatchSize <= 0 {
+		errs = append(errs, errors.New("BATCH_SIZE: must be positive"))
+	}
+	if c.ChunkSize <= 0 || c.ChunkSize > maxChunkSize {
+		errs = append(errs, fmt.Errorf("SYNC_CHUNK_SIZE: must be between 1 and %d", maxChunkSize))
+	}
+	if c.MaxDuration <= 0 {
+		errs = append(errs, errors.New("SYNC_MAX_DURATION: must be positive"))
+	}
+	if c.MaxDocuments < 0 {
+		errs = append(errs, errors.New("SYNC_MAX_DOCUMENTS: must not be negative"))
+	}
+	if c.MaxAttempts <= 0 {
+		errs = append(errs, errors.New("SYNC_MAX_ATTEMPTS: must be positive"))
+	}
+	if c.Schedule != "" {
+		schedule, err := cron.ParseStandard(c.Schedule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SYNC_SCHEDULE: %w", err))
+		} else if interval := minInterval(schedule, time.Now()); interval > 0 && c.ScheduleJitter >= interval {
+			// A later run could otherwise start before an earlier one
+			errs = append(errs, fmt.Errorf("SYNC_SCHEDULE_JITTER: must be less than %s, the shortest gap between runs", interval))
+		}
+	}
+	if c.ScheduleJitter < 0 {
+		errs = append(errs, errors.New("SYNC_SCHEDULE_JITTER: must not be negative"))
+	}
+	if c.StreamPollInterval <= 0 {
+		errs = append(errs, errors.New("SYNC_STREAM_POLL_INTERVAL: must be positive"))
+	}
+	if len(c.Token) < 16 {
+		errs = append(errs, errors.New("SYNC_TOKEN: must be at least 16 characters"))
+	}
+	return errors.Join(errs...)
+}
+
+// ServerConfig holds the HTTP server timeouts. WriteTimeout also bounds a
+// /api/sync/reading?wait=true call, so it is generous and should stay above
+// SYNC_MAX_DURATION.
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" default:"15s"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" default:"5m"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" default:"60s"`
+	// ShutdownTimeout is how long in-flight requests get to finish after
+	// SIGTERM/SIGINT before the server closes them.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s"`
+}
+
+func (c *ServerConfig) Validate() error {
+	var errs []error
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", c.ReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", c.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be positive", d.key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Config is the proxy's complete startup configuration.
+type Config struct {
+	Port string `env:"PORT" default:"8085"`
+	// Log holds LOG_LEVEL and LOKI_URL; LogLevel overrides the level for
+	// the proxy only.
+	Log      logger.Settings
+	LogLevel string `env:"LOG_LEVEL_PROXY"`
+	Server   ServerConfig
+	Sync     SyncConfig
+	DB       db.ConnectConfig
+}
+
+// ValidateMongo checks the settings needed to sync from MongoDB. It is not
+// part of loading, so -migrate and -spool run without them.
+func (c *Config) ValidateMongo() error {
+	var problems []string
+	for _, v := range []struct{ key, value string }{
+		{"MONGO_URI", c.DB.Mongo.URI},
+		{"MONGO_DB_NAME", c.Sync.MongoDBName},
+		{"MONGO_COLLECTION", c.Sync.MongoCollection},
+	} {
+		if v.value == "" {
+			problems = append(problems, v.key+": required but not set")
+		}
+	}
+	if len(problems) > 0 {
+		return &config.Error{Problems: problems}
+	}
+	return nil
+}
+
+// LogSettings returns the logger settings with the proxy's own level.
+func (c *Config) LogSettings() logger.Settings {
+	s := c.Log
+	s.ServiceLevel = c.LogLevel
+	return s
+}
+
+// LoadConfig reads the proxy configuration from, lowest precedence first,
+// the built-in defaults, an optional YAML file (CONFIG_FILE, default
+// config.yaml), .env / ../.env and the environment.
+func LoadConfig() (Config, error) {
+	var cfg Config
+	err := config.LoadService(&cfg)
+	return cfg, err
+}
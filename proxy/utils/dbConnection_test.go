@@ -6,6 +6,9 @@ import (
 	"os"
 	"os/exec"
 	"testing"
+	"time"
+
+	"db"
 )
 
 // --- Mock Driver Implementation ---
@@ -38,34 +41,27 @@ func init() {
 // --- Tests ---
 
 func TestInitPostgres_Success(t *testing.T) {
-	// Set required environment variables
-	os.Setenv("DB_HOST", "localhost")
-	os.Setenv("DB_USER", "testuser")
-	os.Setenv("DB_NAME", "testdb")
-	// Optional
-	os.Setenv("DB_PORT", "5432")
-	os.Setenv("SERVER_DB_PASSWORD", "secret")
-
-	defer func() {
-		os.Unsetenv("DB_HOST")
-		os.Unsetenv("DB_USER")
-		os.Unsetenv("DB_NAME")
-		os.Unsetenv("DB_PORT")
-		os.Unsetenv("SERVER_DB_PASSWORD")
-	}()
-
-	db := InitPostgres("mock-postgres")
-
-	if db == nil {
+	cfg := db.DefaultConnectConfig()
+	cfg.Postgres = db.PostgresConfig{
+		Host:     "localhost",
+		Port:     "5432",
+		User:     "testuser",
+		Password: "secret",
+		Name:     "testdb",
+	}
+
+	sqlDB := InitPostgres("mock-postgres", cfg)
+
+	if sqlDB == nil {
 		t.Fatal("Expected db instance, got nil")
 	}
-	defer db.Close()
+	defer sqlDB.Close()
 }
 
 func TestInitPostgres_MissingEnv(t *testing.T) {
 	if os.Getenv("BE_CRASHER") == "1" {
-		os.Unsetenv("DB_HOST") // Ensure it's missing
-		InitPostgres("mock-postgres")
+		// DB_HOST and friends are missing
+		InitPostgres("mock-postgres", db.DefaultConnectConfig())
 		return
 	}
 
@@ -80,8 +76,8 @@ func TestInitPostgres_MissingEnv(t *testing.T) {
 
 func TestInitMongo_MissingEnv(t *testing.T) {
 	if os.Getenv("MONGO_CRASHER") == "1" {
-		os.Unsetenv("MONGO_URI")
-		InitMongo()
+		// MONGO_URI is missing
+		InitMongo(db.DefaultConnectConfig())
 		return
 	}
 
@@ -98,8 +94,10 @@ func TestInitMongo_ConnectionFail(t *testing.T) {
 	// Tests that InitMongo fails (log.Fatalf) when it cannot connect/ping.
 	// We use a dummy URI that should cause Ping to fail.
 	if os.Getenv("MONGO_PING_CRASHER") == "1" {
-		os.Setenv("MONGO_URI", "mongodb://localhost:27017") // Assuming no mongo running
-		InitMongo()
+		cfg := db.DefaultConnectConfig()
+		cfg.Mongo.URI = "mongodb://localhost:27017" // Assuming no mongo running
+		cfg.Retry.Timeout = 2 * time.Second         // Keep the retry window short
+		InitMongo(cfg)
 		return
 	}
 
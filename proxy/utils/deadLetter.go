@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Source document statuses. ingested and failed documents are picked up by
+// the sync; after SYNC_MAX_ATTEMPTS failures a document is dead_letter, and
+// one whose payload fails its schema is quarantined. Both are left alone
+// until they are requeued.
+const (
+	statusIngested    = "ingested"
+	statusProcessed   = "processed"
+	statusFailed      = "failed"
+	statusDeadLetter  = "dead_letter"
+	statusQuarantined = "quarantined"
+)
+
+const (
+	defaultFailedLimit = 50
+	maxFailedLimit     = 500
+)
+
+// pendingFilter matches the documents a sync should process.
+func pendingFilter() bson.M {
+	return bson.M{"status": bson.M{"$in": bson.A{statusIngested, statusFailed}}}
+}
+
+// FailedDocument is a failed, dead-lettered or quarantined source document
+// as listed by /api/sync/failed.
+type FailedDocument struct {
+	ID            string     `json:"id"`
+	Status        string     `json:"status"`
+	Source        string     `json:"source,omitempty"`
+	EventType     string     `json:"event_type,omitempty"`
+	Error         string     `json:"error"`
+	Attempts      int        `json:"attempts"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+}
+
+// FailedHandler lists failed, dead-lettered and quarantined documents, most
+// recently attempted first. status narrows the list to one of them; limit
+// defaults to 50 (max 500). It needs MongoDB and answers 501 for other
+// sources.
+func (s *ReadingService) FailedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter := bson.M{"status": bson.M{"$in": bson.A{statusFailed, statusDeadLetter, statusQuarantined}}}
+	switch v := r.URL.Query().Get("status"); v {
+	case "":
+	case statusFailed, statusDeadLetter, statusQuarantined:
+		filter["status"] = v
+	default:
+		http.Error(w, "invalid status: want failed, dead_letter or quarantined", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultFailedLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxFailedLimit)
+	}
+
+	coll, ok := s.mongoCollection()
+	if !ok {
+		http.Error(w, "Not supported by the sync source", http.StatusNotImplemented)
+		return
+	}
+	docs, err := listFailed(ctx, coll, filter, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed_documents_query_failed", "error", err)
+		http.Error(w, "Failed to query Mongo", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"documents": docs, "count": len(docs)})
+}
+
+func listFailed(ctx context.Context, coll *mongo.Collection, filter bson.M, limit int) ([]FailedDocument, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "last_attempt_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"payload": 0, "meta": 0})
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []FailedDocument{}
+	for cursor.Next(ctx) {
+		var raw struct {
+			ID            any       `bson:"_id"`
+			Status        string    `bson:"status"`
+			Source        string    `bson:"source"`
+			EventType     string    `bson:"event_type"`
+			Error         string    `bson:"sync_error"`
+			Attempts      int       `bson:"sync_attempts"`
+			LastAttemptAt time.Time `bson:"last_attempt_at"`
+		}
+		if err := cursor.Decode(&raw); err != nil {
+			return nil, err
+		}
+		doc := FailedDocument{
+			ID:        formatDocumentID(raw.ID),
+			Status:    raw.Status,
+			Source:    raw.Source,
+			EventType: raw.EventType,
+			Error:     raw.Error,
+			Attempts:  raw.Attempts,
+		}
+		if !raw.LastAttemptAt.IsZero() {
+			t := raw.LastAttemptAt.UTC()
+			doc.LastAttemptAt = &t
+		}
+		docs = append(docs, doc)
+	}
+	return docs, cursor.Err()
+}
+
+func formatDocumentID(id any) string {
+	if rv, ok := id.(bson.RawValue); ok {
+		if s, ok := rv.StringValueOK(); ok {
+			return s
+		}
+		if oid, ok := rv.ObjectIDOK(); ok {
+			return oid.Hex()
+		}
+	}
+	if oid, ok := id.(primitive.ObjectID); ok {
+		return oid.Hex()
+	}
+	return fmt.Sprint(id)
+}
+
+// requeueRequest selects documents to requeue: the listed ObjectIDs, or with
+// all set, every document in status (default dead_letter).
+type requeueRequest struct {
+	IDs    []string `json:"ids"`
+	All    bool     `json:"all"`
+	Status string   `json:"status"`
+}
+
+// RequeueHandler sets failed, dead-lettered or quarantined documents back to
+// ingested with their attempt count cleared, so the next sync retries them.
+// Like FailedHandler, it answers 501 unless the source is MongoDB.
+func (s *ReadingService) RequeueHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	coll, ok := s.mongoCollection()
+	if !ok {
+		http.Error(w, "Not supported by the sync source", http.StatusNotImplemented)
+		return
+	}
+
+	var req requeueRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	filter, err := req.filter()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"status": statusIngested},
+		"$unset": bson.M{"sync_attempts": "", "sync_error": "", "last_attempt_at": ""},
+	}
+	res, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		slog.ErrorContext(ctx, "requeue_failed", "error", err)
+		http.Error(w, "Failed to requeue documents", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(ctx, "documents_requeued", "count", res.ModifiedCount, "remote_ip", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"requeued": res.ModifiedCount})
+}
+
+func (req requeueRequest) filter() (bson.M, error) {
+	if req.All == (len(req.IDs) > 0) {
+		return nil, errors.New("set either ids or all")
+	}
+
+	if req.All {
+		status := req.Status
+		if status == "" {
+			status = statusDeadLetter
+		}
+		if status != statusFailed && status != statusDeadLetter && status != statusQuarantined {
+			return nil, errors.New("invalid status: want failed, dead_letter or quarantined")
+		}
+		return bson.M{"status": status}, nil
+	}
+
+	ids := make(bson.A, 0, len(req.IDs))
+	for _, v := range req.IDs {
+		oid, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", v)
+		}
+		ids = append(ids, oid)
+	}
+	return bson.M{
+		"_id":    bson.M{"$in": ids},
+		"status": bson.M{"$in": bson.A{statusFailed, statusDeadLetter, statusQuarantined}},
+	}, nil
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestWriteChunk_PostgresDown(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	service := &ReadingService{DB: db}
+	job, _ := service.jobs.start(TriggerAPI)
+	chunk := []pendingDoc{{id: primitive.NewObjectID()}, {id: primitive.NewObjectID()}}
+
+	// An outage is not the documents' fault: nothing is marked failed or acked
+	failedIDs, failures, err := service.writeChunk(context.Background(), chunk, job)
+
+	if !errors.Is(err, errPostgresUnavailable) {
+		t.Errorf("expected errPostgresUnavailable, got %v", err)
+	}
+	if len(failedIDs) != 2 || len(failures) != 0 {
+		t.Errorf("expected 2 failed ids and no document failures, got %v, %v", failedIDs, failures)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+	}
+}
+
+func TestSyncReadingHandler_PostgresDownFailsJob(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var lines []string
+	for range 3 {
+		lines = append(lines, `{"_id":{"$oid":"`+primitive.NewObjectID().Hex()+`"},"status":"ingested"}`)
+	}
+	dir, path := writeSpool(t, lines...)
+	service := &ReadingService{
+		DB:     db,
+		Source: &SpoolSource{Dir: dir},
+		Sync:   SyncConfig{BatchSize: 1, ChunkSize: 1, MaxDocuments: 100, MaxDuration: time.Minute},
+	}
+
+	// The first chunk fails and the ping confirms the outage; the sync must
+	// stop there instead of failing every remaining page
+	mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectExec("INSERT INTO etl_runs").
+		WithArgs(sqlmock.AnyArg(), "reading", "api", "failed", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnError(errors.New("connection refused"))
+
+	req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+	w := httptest.NewRecorder()
+	service.SyncReadingHandler(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+	var job SyncJob
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if job.Status != JobFailed || job.Fetched != 1 || job.Drained {
+		t.Errorf("expected a failed run that stopped after the first page, got %+v", job)
+	}
+	if !service.LastSync().IsZero() {
+		t.Error("a failed sync must not count as the last successful sync")
+	}
+	for i, doc := range readSpool(t, path) {
+		if doc["status"] != statusIngested {
+			t.Errorf("line %d: expected the document to stay ingested, got %v", i+1, doc["status"])
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+	}
+}
+
+func TestFailedHandler(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("lists_documents", func(mt *mtest.T) {
+		service := &ReadingService{MongoClient: mt.Client, Sync: SyncConfig{MongoDBName: "testdb", MongoCollection: "testcoll"}}
+
+		id := primitive.NewObjectID()
+		attempted := time.Date(2026, 1, 4, 10, 0, 0, 0, time.UTC)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+			bson.D{
+				{Key: "_id", Value: id},
+				{Key: "status", Value: "dead_letter"},
+				{Key: "source", Value: "kindle"},
+				{Key: "sync_error", Value: "insert: invalid input"},
+				{Key: "sync_attempts", Value: 5},
+				{Key: "last_attempt_at", Value: attempted},
+			},
+			bson.D{{Key: "_id", Value: "legacy-1"}, {Key: "status", Value: "failed"}},
+		))
+
+		req := httptest.NewRequest("GET", "/api/sync/failed?status=dead_letter", nil)
+		w := httptest.NewRecorder()
+		service.FailedHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body struct {
+			Documents []FailedDocument `json:"documents"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if len(body.Documents) != 2 {
+			t.Fatalf("expected 2 documents, got %d", len(body.Documents))
+		}
+		first := body.Documents[0]
+		if first.ID != id.Hex() || first.Attempts != 5 || first.LastAttemptAt == nil || !first.LastAttemptAt.Equal(attempted) {
+			t.Errorf("unexpected document: %+v", first)
+		}
+		if body.Documents[1].ID != "legacy-1" {
+			t.Errorf("expected non-ObjectID _id to be listed as-is, got %q", body.Documents[1].ID)
+		}
+	})
+
+	mt.Run("bad_requests", func(mt *mtest.T) {
+		service := &ReadingService{MongoClient: mt.Client}
+
+		for _, target := range []string{"/api/sync/failed?status=ingested", "/api/sync/failed?limit=-1"} {
+			w := httptest.NewRecorder()
+			service.FailedHandler(w, httptest.NewRequest("GET", target, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status 400, got %d", target, w.Code)
+			}
+		}
+	})
+}
+
+func TestRequeueHandler(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	id := primitive.NewObjectID().Hex()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "by_ids", body: `{"ids":["` + id + `"]}`, expectedStatus: http.StatusOK},
+		{name: "all_dead_letters", body: `{"all":true}`, expectedStatus: http.StatusOK},
+		{name: "neither", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "both", body: `{"all":true,"ids":["` + id + `"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "bad_id", body: `{"ids":["nope"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "bad_status", body: `{"all":true,"status":"processed"}`, expectedStatus: http.StatusBadRequest},
+		{name: "bad_json", body: `{`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			service := &ReadingService{MongoClient: mt.Client, Sync: SyncConfig{MongoDBName: "testdb", MongoCollection: "testcoll"}}
+			mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 3}, {Key: "nModified", Value: 3}})
+
+			req := httptest.NewRequest("POST", "/api/sync/failed/requeue", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			service.RequeueHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && !strings.Contains(w.Body.String(), `"requeued":3`) {
+				t.Errorf("expected requeued count, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeadLetterHandlers_SpoolSource(t *testing.T) {
+	dir, _ := writeSpool(t, `{"_id":{"$oid":"`+primitive.NewObjectID().Hex()+`"},"status":"dead_letter"}`)
+	service := &ReadingService{Source: &SpoolSource{Dir: dir}}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		handler http.HandlerFunc
+	}{
+		{"list", "GET", "/api/sync/failed", "", service.FailedHandler},
+		{"requeue", "POST", "/api/sync/failed/requeue", `{"all":true}`, service.RequeueHandler},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			if w.Code != http.StatusNotImplemented {
+				t.Errorf("expected status 501, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Dry-run actions for a previewed document.
+const (
+	ActionInsert = "insert"
+	ActionSkip   = "skip"
+	ActionReject = "reject"
+)
+
+// maxDryRunDocuments caps the documents listed in a dry-run report; the
+// counts cover every document.
+const maxDryRunDocuments = 100
+
+// DryRunReport is what a sync would do with the pending documents, worked
+// out without writing to Postgres or Mongo.
+type DryRunReport struct {
+	Pending     int64            `json:"pending"` // ingested or failed documents in Mongo
+	Fetched     int              `json:"fetched"`
+	WouldInsert int              `json:"would_insert"`
+	WouldSkip   int              `json:"would_skip"` // already in Postgres
+	Rejected    int              `json:"rejected"`   // would be marked failed or quarantined
+	Validation  ValidationReport `json:"validation"`
+	Documents   []DryRunDocument `json:"documents"`
+	DurationMS  int64            `json:"duration_ms"`
+}
+
+// DryRunDocument is the previewed outcome for one document.
+type DryRunDocument struct {
+	ID        string `json:"id"`
+	Source    string `json:"source,omitempty"`
+	EventType string `json:"event_type,omitempty"`
+	Action    string `json:"action"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (r *DryRunReport) add(d DryRunDocument) {
+	switch d.Action {
+	case ActionInsert:
+		r.WouldInsert++
+	case ActionSkip:
+		r.WouldSkip++
+	case ActionReject:
+		r.Rejected++
+	}
+	if len(r.Documents) < maxDryRunDocuments {
+		r.Documents = append(r.Documents, d)
+	}
+}
+
+// DryRun fetches, decodes, validates and transforms the pending documents as
+// a sync would, within the same SYNC_MAX_DOCUMENTS and SYNC_MAX_DURATION
+// budgets, and reports which would be inserted, skipped as duplicates or
+// rejected. It only reads, through Source.Page: nothing is written to
+// Postgres or the source, and the ETL metrics are left alone.
+func (s *ReadingService) DryRun(ctx context.Context) (DryRunReport, error) {
+	start := time.Now()
+	report := DryRunReport{Documents: []DryRunDocument{}}
+	src := s.source()
+
+	pending, err := src.Pending(ctx)
+	if err != nil {
+		return report, fmt.Errorf("count pending documents: %w", err)
+	}
+	report.Pending = pending
+
+	batchSize := s.Sync.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100 // Default
+	}
+	chunkSize := s.Sync.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 100 // Default
+	}
+	var deadline time.Time
+	if s.Sync.MaxDuration > 0 {
+		deadline = start.Add(s.Sync.MaxDuration)
+	}
+
+	chunk := make([]pendingDoc, 0, chunkSize)
+	flush := func() error {
+		existing, err := s.existingIDs(ctx, chunk)
+		if err != nil {
+			return fmt.Errorf("query Postgres: %w", err)
+		}
+		for _, p := range chunk {
+			d := DryRunDocument{ID: p.id.Hex(), Source: p.source, EventType: p.eventType, Action: ActionInsert}
+			if existing[d.ID] {
+				d.Action = ActionSkip
+			}
+			report.add(d)
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	// Nothing is marked, so each page starts after the last _id seen
+	var after any
+	for !s.stopping.Load() && (deadline.IsZero() || time.Now().Before(deadline)) {
+		limit := batchSize
+		if s.Sync.MaxDocuments > 0 {
+			limit = min(limit, s.Sync.MaxDocuments-report.Fetched)
+		}
+		if limit <= 0 {
+			break
+		}
+
+		cursor, err := src.Page(ctx, after, limit)
+		if err != nil {
+			return report, fmt.Errorf("fetch documents: %w", err)
+		}
+		fetched := 0
+		for cursor.Next(ctx) {
+			fetched++
+			report.Fetched++
+			if id, err := cursor.Document().LookupErr("_id"); err == nil {
+				// Copied, the cursor may reuse its buffer
+				after = bson.RawValue{Type: id.Type, Value: bytes.Clone(id.Value)}
+			}
+
+			p, hasSchema, rej := s.decodeDocument(cursor.Document())
+			if rej != nil {
+				d := DryRunDocument{Action: ActionReject, Reason: rej.reason}
+				if rej.id != nil {
+					d.ID = formatDocumentID(rej.id)
+				}
+				if rej.quarantine {
+					d.Source, d.EventType = p.source, p.eventType
+					report.Validation.Quarantined++
+					if len(report.Validation.Failures) < maxJobErrors {
+						report.Validation.Failures = append(report.Validation.Failures, d.ID+": "+rej.reason)
+					}
+				}
+				report.add(d)
+				continue
+			}
+			if hasSchema {
+				report.Validation.Validated++
+			} else {
+				report.Validation.NoSchema++
+			}
+
+			chunk = append(chunk, p)
+			if len(chunk) == chunkSize {
+				if err := flush(); err != nil {
+					cursor.Close(ctx)
+					return report, err
+				}
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return report, fmt.Errorf("fetch documents: %w", err)
+		}
+		if fetched < limit {
+			break
+		}
+	}
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return report, err
+		}
+	}
+
+	report.DurationMS = time.Since(start).Milliseconds()
+	return report, nil
+}
+
+// existingIDs returns which of the chunk's mongo_ids are already in
+// reading_analytics.
+func (s *ReadingService) existingIDs(ctx context.Context, chunk []pendingDoc) (map[string]bool, error) {
+	placeholders := make([]string, len(chunk))
+	args := make([]any, len(chunk))
+	for i, p := range chunk {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = p.id.Hex()
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT mongo_id FROM reading_analytics WHERE mongo_id IN (`+strings.Join(placeholders, ", ")+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool, len(chunk))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		existing[id] = true
+	}
+	return existing, rows.Err()
+}
+
+// dryRunHandler answers ?dry_run=true on the sync endpoint with the report.
+// It does not take the job slot, so it can run beside a real sync.
+func (s *ReadingService) dryRunHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	report, err := s.DryRun(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "sync_dry_run_failed", "error", err)
+		http.Error(w, "Dry run failed", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(ctx, "sync_dry_run",
+		"fetched", report.Fetched,
+		"would_insert", report.WouldInsert,
+		"would_skip", report.WouldSkip,
+		"rejected", report.Rejected,
+	)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
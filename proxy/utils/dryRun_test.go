@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestSyncReadingHandler_DryRun(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	syncConfig := SyncConfig{MongoDBName: "testdb", MongoCollection: "testcoll", ChunkSize: 10}
+
+	mt.Run("reports_without_writing", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		service := &ReadingService{DB: db, MongoClient: mt.Client, Sync: syncConfig}
+
+		newID, dupID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch, bson.D{{Key: "n", Value: 3}}),
+			mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: newID}, {Key: "source", Value: "kindle"}, {Key: "event_type", Value: "highlight"}},
+				bson.D{{Key: "_id", Value: dupID}, {Key: "source", Value: "kindle"}, {Key: "event_type", Value: "highlight"}},
+				bson.D{{Key: "_id", Value: "legacy-1"}},
+			),
+		)
+
+		// Only a read to find duplicates; no transaction, insert or etl_runs row
+		mock.ExpectQuery(`SELECT mongo_id FROM reading_analytics WHERE mongo_id IN \(\$1, \$2\)`).
+			WithArgs(newID.Hex(), dupID.Hex()).
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(dupID.Hex()))
+
+		fetchedBefore := testutil.ToFloat64(etlDocumentsFetched)
+		req := httptest.NewRequest("POST", "/api/sync/reading?dry_run=true", nil)
+		w := httptest.NewRecorder()
+		service.SyncReadingHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var report DryRunReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if report.Pending != 3 || report.Fetched != 3 || report.WouldInsert != 1 || report.WouldSkip != 1 || report.Rejected != 1 {
+			t.Errorf("unexpected counts: %+v", report)
+		}
+		actions := map[string]string{}
+		for _, d := range report.Documents {
+			actions[d.ID] = d.Action
+		}
+		if actions[newID.Hex()] != ActionInsert || actions[dupID.Hex()] != ActionSkip || actions["legacy-1"] != ActionReject {
+			t.Errorf("unexpected documents: %+v", report.Documents)
+		}
+
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName != "aggregate" && e.CommandName != "find" {
+				t.Errorf("expected only reads from Mongo, got %q", e.CommandName)
+			}
+		}
+		if got := testutil.ToFloat64(etlDocumentsFetched) - fetchedBefore; got != 0 {
+			t.Errorf("expected the ETL metrics to be left alone, got %v fetched", got)
+		}
+		if _, running := service.jobs.start(TriggerAPI); !running {
+			t.Error("expected a dry run not to hold the job slot")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+
+	mt.Run("pages_after_last_id", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		pageConfig := syncConfig
+		pageConfig.BatchSize = 2
+		service := &ReadingService{DB: db, MongoClient: mt.Client, Sync: pageConfig}
+
+		ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch, bson.D{{Key: "n", Value: 3}}),
+			mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: ids[0]}}, bson.D{{Key: "_id", Value: ids[1]}}),
+			mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch, bson.D{{Key: "_id", Value: ids[2]}}),
+		)
+		mock.ExpectQuery("SELECT mongo_id FROM reading_analytics").
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}))
+
+		report, err := service.DryRun(context.Background())
+		if err != nil {
+			t.Fatalf("DryRun: %v", err)
+		}
+		if report.Fetched != 3 || report.WouldInsert != 3 {
+			t.Errorf("unexpected counts: %+v", report)
+		}
+
+		// Every page is sorted by _id; the second starts after the first's
+		// last _id instead of listing the ones already seen
+		var finds []bson.Raw
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName == "find" {
+				finds = append(finds, e.Command)
+			}
+		}
+		if len(finds) != 2 {
+			t.Fatalf("expected 2 find commands, got %d", len(finds))
+		}
+		for _, find := range finds {
+			if _, err := find.LookupErr("sort", "_id"); err != nil {
+				t.Errorf("expected a sort on _id, got %s", find)
+			}
+		}
+		gt, err := finds[1].LookupErr("filter", "_id", "$gt")
+		if err != nil || gt.ObjectID() != ids[1] {
+			t.Errorf("expected the second page to start after %s, got %s", ids[1].Hex(), finds[1])
+		}
+		if _, err := finds[1].LookupErr("filter", "_id", "$nin"); err == nil {
+			t.Errorf("expected no $nin in the page filter, got %s", finds[1])
+		}
+	})
+
+	mt.Run("postgres_error", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		service := &ReadingService{DB: db, MongoClient: mt.Client, Sync: syncConfig}
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+			mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch, bson.D{{Key: "_id", Value: primitive.NewObjectID()}}),
+		)
+		mock.ExpectQuery("SELECT mongo_id FROM reading_analytics").WillReturnError(errors.New("connection refused"))
+
+		w := httptest.NewRecorder()
+		service.SyncReadingHandler(w, httptest.NewRequest("POST", "/api/sync/reading?dry_run=true", nil))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sync triggers, recorded with each run.
+const (
+	TriggerTimer  = "timer"
+	TriggerManual = "manual"
+	TriggerAPI    = "api"
+	// The in-process scheduler and streaming catch-up or polling runs; the
+	// API does not accept these.
+	TriggerSchedule = "schedule"
+	TriggerStream   = "stream"
+)
+
+const (
+	defaultRunsLimit = 20
+	maxRunsLimit     = 200
+
+	// readingPipeline names the reading sync in etl_runs.
+	readingPipeline = "reading"
+	// maxErrorSummary bounds etl_runs.error_summary.
+	maxErrorSummary = 2000
+	// recordRunTimeout bounds the etl_runs insert, which runs after the
+	// request context may already be gone.
+	recordRunTimeout = 5 * time.Second
+)
+
+// ETLRun is one row of etl_runs as returned by /api/sync/runs.
+type ETLRun struct {
+	ID           int64     `json:"id"`
+	JobID        string    `json:"job_id"`
+	Pipeline     string    `json:"pipeline"`
+	Trigger      string    `json:"trigger"`
+	Status       string    `json:"status"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	DurationMS   int64     `json:"duration_ms"`
+	Fetched      int       `json:"fetched"`
+	Inserted     int       `json:"inserted"`
+	Skipped      int       `json:"skipped"`
+	Failed       int       `json:"failed"`
+	ErrorSummary string    `json:"error_summary,omitempty"`
+}
+
+func parseTrigger(v string) (string, error) {
+	switch v {
+	case "":
+		return TriggerAPI, nil
+	case TriggerTimer, TriggerManual, TriggerAPI:
+		return v, nil
+	}
+	return "", errors.New("invalid trigger: want timer, manual or api")
+}
+
+// recordRun writes a finished job to etl_runs. A failure is logged but does
+// not fail the sync, whose data is already committed.
+func (s *ReadingService) recordRun(ctx context.Context, job SyncJob) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordRunTimeout)
+	defer cancel()
+
+	var summary sql.NullString
+	if len(job.Errors) > 0 {
+		text := strings.Join(job.Errors, "; ")
+		if len(text) > maxErrorSummary {
+			text = text[:maxErrorSummary] + "..."
+		}
+		summary = sql.NullString{String: text, Valid: true}
+	}
+
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO etl_runs (job_id, pipeline, trigger, status, started_at, finished_at, duration_ms, fetched, inserted, skipped, failed, error_summary)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		job.ID, readingPipeline, job.Trigger, job.Status, job.StartedAt, *job.FinishedAt,
+		job.FinishedAt.Sub(job.StartedAt).Milliseconds(),
+		job.Fetched, job.Inserted, job.Skipped, job.Failed, summary,
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "etl_run_record_failed", "job_id", job.ID, "error", err)
+	}
+}
+
+// RunsHandler lists recent sync runs, newest first. limit defaults to 20
+// (max 200); status and trigger filter exactly.
+func (s *ReadingService) RunsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, args, err := runsQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	runs, err := s.queryRuns(ctx, query, args)
+	if err != nil {
+		slog.ErrorContext(ctx, "etl_runs_query_failed", "error", err)
+		http.Error(w, "Failed to query runs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"runs": runs, "count": len(runs)})
+}
+
+func runsQuery(values url.Values) (string, []any, error) {
+	limit := defaultRunsLimit
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return "", nil, errors.New("invalid limit: must be a positive integer")
+		}
+		limit = min(n, maxRunsLimit)
+	}
+
+	var where []string
+	var args []any
+	for _, col := range []string{"status", "trigger"} {
+		if v := values.Get(col); v != "" {
+			args = append(args, v)
+			where = append(where, col+" = $"+strconv.Itoa(len(args)))
+		}
+	}
+
+	query := `SELECT id, job_id, pipeline, trigger, status, started_at, finished_at, duration_ms, fetched, inserted, skipped, failed, error_summary FROM etl_runs`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, limit)
+	query += " ORDER BY started_at DESC, id DESC LIMIT $" + strconv.Itoa(len(args))
+	return query, args, nil
+}
+
+func (s *ReadingService) queryRuns(ctx context.Context, query string, args []any) ([]ETLRun, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []ETLRun{}
+	for rows.Next() {
+		var run ETLRun
+		var summary sql.NullString
+		if err := rows.Scan(&run.ID, &run.JobID, &run.Pipeline, &run.Trigger, &run.Status, &run.StartedAt, &run.FinishedAt,
+			&run.DurationMS, &run.Fetched, &run.Inserted, &run.Skipped, &run.Failed, &summary); err != nil {
+			return nil, err
+		}
+		run.ErrorSummary = summary.String
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
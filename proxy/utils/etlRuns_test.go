@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var runColumns = []string{"id", "job_id", "pipeline", "trigger", "status", "started_at", "finished_at", "duration_ms", "fetched", "inserted", "skipped", "failed", "error_summary"}
+
+func TestRunsHandler(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	service := &ReadingService{DB: db}
+	started := time.Date(2026, 1, 4, 10, 0, 0, 0, time.UTC)
+	finished := started.Add(90 * time.Second)
+
+	mock.ExpectQuery(`FROM etl_runs WHERE status = \$1 AND trigger = \$2 ORDER BY started_at DESC, id DESC LIMIT \$3`).
+		WithArgs("failed", "timer", 5).
+		WillReturnRows(sqlmock.NewRows(runColumns).
+			AddRow(7, "JOB7", "reading", "timer", "failed", started, finished, 90000, 10, 8, 1, 1, "abc: insert: disk full").
+			AddRow(6, "JOB6", "reading", "timer", "failed", started, finished, 90000, 0, 0, 0, 0, nil))
+
+	req := httptest.NewRequest("GET", "/api/sync/runs?status=failed&trigger=timer&limit=5", nil)
+	w := httptest.NewRecorder()
+	service.RunsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Runs  []ETLRun `json:"runs"`
+		Count int      `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Count != 2 || body.Runs[0].ErrorSummary == "" || body.Runs[1].ErrorSummary != "" {
+		t.Errorf("unexpected runs: %+v", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+	}
+}
+
+func TestRunsHandler_BadRequests(t *testing.T) {
+	service := &ReadingService{}
+
+	for _, target := range []string{"/api/sync/runs?limit=0", "/api/sync/runs?limit=many"} {
+		w := httptest.NewRecorder()
+		service.RunsHandler(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	service.RunsHandler(w, httptest.NewRequest("POST", "/api/sync/runs", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
+
+func TestParseTrigger(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{input: "", expected: TriggerAPI},
+		{input: "timer", expected: TriggerTimer},
+		{input: "manual", expected: TriggerManual},
+		{input: "cron", expectError: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTrigger(tt.input)
+		if (err != nil) != tt.expectError || got != tt.expected {
+			t.Errorf("parseTrigger(%q) = %q, %v", tt.input, got, err)
+		}
+	}
+}
+
+func TestRecordRun_TruncatesErrorSummary(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	started := time.Now().UTC()
+	finished := started.Add(2 * time.Second)
+	job := SyncJob{
+		ID:         "JOB1",
+		Status:     JobSucceeded,
+		Trigger:    TriggerManual,
+		Failed:     1,
+		Errors:     []string{strings.Repeat("x", maxErrorSummary+10)},
+		StartedAt:  started,
+		FinishedAt: &finished,
+	}
+
+	mock.ExpectExec("INSERT INTO etl_runs").
+		WithArgs("JOB1", "reading", "manual", "succeeded", started, finished, int64(2000), 0, 0, 0, 1,
+			strings.Repeat("x", maxErrorSummary)+"...").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	(&ReadingService{DB: db}).recordRun(context.Background(), job)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"logger"
+)
+
+// readinessTimeout bounds each dependency ping so a hung database cannot
+// hang the probe.
+const readinessTimeout = 2 * time.Second
+
+type dependencyStatus struct {
+	Status    string  `json:"status"` // "up" or "down"
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readinessReport struct {
+	Status       string                      `json:"status"` // "ready" or "unavailable"
+	Dependencies map[string]dependencyStatus `json:"dependencies"`
+	LastSync     *time.Time                  `json:"last_sync"`
+}
+
+// HealthzHandler is the liveness probe: it only shows the process is serving.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// ReadyzHandler is the readiness probe. It pings Postgres and MongoDB in
+// parallel and answers 503 if either is down.
+func (s *ReadingService) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	checks := map[string]func(context.Context) error{
+		"postgres": func(ctx context.Context) error {
+			if s.DB == nil {
+				return errors.New("not connected")
+			}
+			return s.DB.PingContext(ctx)
+		},
+		"mongodb": func(ctx context.Context) error {
+			if s.MongoClient == nil {
+				return errors.New("not connected")
+			}
+			return s.MongoClient.Ping(ctx, nil)
+		},
+	}
+
+	report := readinessReport{Status: "ready", Dependencies: make(map[string]dependencyStatus, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := pingDependency(ctx, check)
+			mu.Lock()
+			report.Dependencies[name] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for name, dep := range report.Dependencies {
+		if dep.Status != "up" {
+			report.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+			slog.WarnContext(ctx, "readiness_check_failed", "dependency", name, "error", dep.Error)
+		}
+	}
+	if last := s.LastSync(); !last.IsZero() {
+		report.LastSync = &last
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+func pingDependency(ctx context.Context, ping func(context.Context) error) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	status := dependencyStatus{
+		Status:    "up",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		status.Status = "down"
+		status.Error = logger.ScrubString(err.Error())
+	}
+	return status
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestHealthzHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	w := httptest.NewRecorder()
+	HealthzHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name           string
+		postgresErr    error
+		mongoDown      bool
+		synced         bool
+		expectedStatus int
+		expectedDown   string
+	}{
+		{name: "all_up", synced: true, expectedStatus: http.StatusOK},
+		{name: "postgres_down", postgresErr: errors.New("connection refused"), expectedStatus: http.StatusServiceUnavailable, expectedDown: "postgres"},
+		{name: "mongo_down", mongoDown: true, expectedStatus: http.StatusServiceUnavailable, expectedDown: "mongodb"},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectPing().WillReturnError(tt.postgresErr)
+
+			if tt.mongoDown {
+				mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutting down"}))
+			} else {
+				mt.AddMockResponses(mtest.CreateSuccessResponse())
+			}
+
+			service := &ReadingService{DB: db, MongoClient: mt.Client}
+			if tt.synced {
+				service.lastSync.Store(time.Now().UnixNano())
+			}
+
+			req := httptest.NewRequest("GET", "/readyz", nil)
+			w := httptest.NewRecorder()
+			service.ReadyzHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			var report readinessReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			for name, dep := range report.Dependencies {
+				expected := "up"
+				if name == tt.expectedDown {
+					expected = "down"
+				}
+				if dep.Status != expected {
+					t.Errorf("%s: expected %s, got %s (%s)", name, expected, dep.Status, dep.Error)
+				}
+			}
+			if len(report.Dependencies) != 2 {
+				t.Errorf("expected 2 dependencies, got %v", report.Dependencies)
+			}
+			if tt.synced != (report.LastSync != nil) {
+				t.Errorf("expected last_sync set = %v, got %v", tt.synced, report.LastSync)
+			}
+		})
+	}
+}
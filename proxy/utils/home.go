@@ -5,7 +5,13 @@ import (
 	"net/http"
 )
 
+// HomeHandler answers "/" only; the default mux routes every unmatched path
+// here, so anything else is a 404.
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Welcome to the Observability Hub."})
 }
@@ -24,6 +24,13 @@ func TestHomeHandler(t *testing.T) {
 			expectedContentType: "application/json",
 			expectedMessage:     "Welcome to the Observability Hub.",
 		},
+		{
+			name:                "unknown path",
+			method:              "GET",
+			path:                "/does-not-exist",
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: "text/plain; charset=utf-8",
+		},
 	}
 
 	for _, tt := range tests {
@@ -44,6 +51,10 @@ func TestHomeHandler(t *testing.T) {
 					contentType, tt.expectedContentType)
 			}
 
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
 			var response map[string]string
 			err := json.Unmarshal(rr.Body.Bytes(), &response)
 			if err != nil {
@@ -3,29 +3,97 @@ package utils
 import (
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
+
+	"logger"
 )
 
-// WithLogging wraps an http.HandlerFunc to log request details
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// WithLogging wraps an http.HandlerFunc to log request details and record
+// the request in the proxy_http_* metrics.
+// It accepts the caller's X-Request-ID (or generates one), echoes it back in
+// the response and stores it, along with any W3C traceparent IDs, in the
+// request context so every log line of the request carries it.
 func WithLogging(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		requestID := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = logger.NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := logger.WithRequestID(r.Context(), requestID)
+		if traceID, spanID, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = logger.WithTrace(ctx, traceID, spanID)
+		}
+		r = r.WithContext(ctx)
+
 		// Wrap the ResponseWriter to capture the status code
 		lrw := newLoggingResponseWriter(w)
 
 		next(lrw, r)
 
-		slog.Info("request_processed",
+		duration := time.Since(start)
+		observeRequest(r.Pattern, r.Method, lrw.statusCode, duration.Seconds())
+		slog.InfoContext(ctx, "request_processed",
 			"http_method", r.Method,
 			"path", r.URL.Path,
 			"remote_ip", r.RemoteAddr,
 			"status", lrw.statusCode,
-			"duration", time.Since(start).String(),
+			"duration", duration.String(),
 		)
 	}
 }
 
+// isValidRequestID only accepts short IDs made of safe characters, so callers
+// cannot inject arbitrary content into logs and response headers.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseTraceparent extracts trace and span IDs from a W3C traceparent header
+// ("00-<32 hex trace id>-<16 hex span id>-<2 hex flags>").
+func parseTraceparent(header string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", false
+	}
+	for _, p := range parts {
+		if !isLowerHex(p) {
+			return "", "", false
+		}
+	}
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 // loggingResponseWriter wraps http.ResponseWriter to capture the status code.
 type loggingResponseWriter struct {
 	http.ResponseWriter
@@ -3,11 +3,14 @@ package utils
 import (
 	"bytes"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+
+	"logger"
 )
 
 func TestWithLogging(t *testing.T) {
@@ -74,3 +77,89 @@ func TestWithLogging(t *testing.T) {
 		})
 	}
 }
+
+func TestWithLogging_RequestID(t *testing.T) {
+	tests := []struct {
+		name       string
+		incomingID string
+		keepsID    bool
+	}{
+		{
+			name:       "accepts caller request id",
+			incomingID: "sync-timer-20260104",
+			keepsID:    true,
+		},
+		{
+			name: "generates missing request id",
+		},
+		{
+			name:       "replaces unsafe request id",
+			incomingID: "bad id\nwith newline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			origLogger := slog.Default()
+			defer slog.SetDefault(origLogger)
+			slog.SetDefault(slog.New(logger.NewContextHandler(slog.NewTextHandler(&buf, nil))))
+
+			var ctxID string
+			handler := WithLogging(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = logger.RequestID(r.Context())
+				slog.InfoContext(r.Context(), "inner_handler")
+			})
+
+			req := httptest.NewRequest("POST", "/api/sync/reading", nil)
+			if tt.incomingID != "" {
+				req.Header.Set(RequestIDHeader, tt.incomingID)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			echoed := rr.Header().Get(RequestIDHeader)
+			if echoed == "" {
+				t.Fatal("response is missing X-Request-ID")
+			}
+			if echoed != ctxID {
+				t.Errorf("context request id %q does not match echoed %q", ctxID, echoed)
+			}
+			if tt.keepsID && echoed != tt.incomingID {
+				t.Errorf("expected request id %q to be kept, got %q", tt.incomingID, echoed)
+			}
+			if !tt.keepsID && echoed == tt.incomingID {
+				t.Errorf("expected request id %q to be replaced", tt.incomingID)
+			}
+			if got := strings.Count(buf.String(), "request_id="+echoed); got != 2 {
+				t.Errorf("expected both log lines to carry the request id, got %d: %s", got, buf.String())
+			}
+		})
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header  string
+		traceID string
+		spanID  string
+		ok      bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", "", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", "", false},
+		{"not-a-traceparent", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			traceID, spanID, ok := parseTraceparent(tt.header)
+			if ok != tt.ok || traceID != tt.traceID || spanID != tt.spanID {
+				t.Errorf("parseTraceparent(%q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.header, traceID, spanID, ok, tt.traceID, tt.spanID, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsRegistry holds everything served on /metrics. A dedicated registry
+// keeps the output to the proxy's own metrics plus Go runtime and process
+// stats.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_http_requests_total",
+		Help: "HTTP requests handled, by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "proxy_http_request_duration_seconds",
+		Help:    "HTTP request latency, by route pattern, method and status code.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method", "status"})
+
+	etlDocumentsFetched = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_documents_fetched_total",
+		Help: "Documents read from MongoDB by the reading sync.",
+	})
+	etlDocumentsInserted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_documents_inserted_total",
+		Help: "Documents newly written to reading_analytics.",
+	})
+	etlDocumentsSkipped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_documents_skipped_total",
+		Help: "Documents not written because they were already in reading_analytics.",
+	})
+	etlDocumentsAcked = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_documents_acked_total",
+		Help: "Documents marked processed in MongoDB after a successful insert.",
+	})
+	etlBacklog = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "proxy_etl_backlog_documents",
+		Help: "Ingested documents left in MongoDB after the last sync.",
+	})
+	etlChunkDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "proxy_etl_chunk_duration_seconds",
+		Help:    "Time to write one chunk of documents to PostgreSQL in a single transaction.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	})
+	etlDecodeFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_decode_failures_total",
+		Help: "Documents skipped because they could not be decoded or had no ObjectID.",
+	})
+	etlInsertFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_insert_failures_total",
+		Help: "Documents that failed to insert into PostgreSQL.",
+	})
+	etlDocumentsMarkedFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_documents_marked_failed_total",
+		Help: "Documents marked failed or dead_letter in MongoDB because they could not be synced.",
+	})
+	etlDocumentsQuarantined = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_documents_quarantined_total",
+		Help: "Documents quarantined in MongoDB because their payload failed schema validation.",
+	})
+	etlAckFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_ack_failures_total",
+		Help: "Documents inserted but not marked processed in MongoDB.",
+	})
+	etlStreamEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_etl_stream_events_total",
+		Help: "Insert events received from the MongoDB change stream.",
+	})
+	etlStreamMode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "proxy_etl_stream_mode",
+		Help: "1 for the mode the streaming sync is in: streaming or polling.",
+	}, []string{"mode"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		etlDocumentsFetched,
+		etlDocumentsInserted,
+		etlDocumentsSkipped,
+		etlDocumentsAcked,
+		etlBacklog,
+		etlChunkDuration,
+		etlDecodeFailures,
+		etlInsertFailures,
+		etlAckFailures,
+		etlDocumentsMarkedFailed,
+		etlDocumentsQuarantined,
+		etlStreamEvents,
+		etlStreamMode,
+	)
+}
+
+// MetricsHandler serves the Prometheus text format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// observeRequest records one handled request. route is the ServeMux pattern
+// rather than the raw path, so unknown URLs cannot blow up the label set.
+func observeRequest(route, method string, status int, seconds float64) {
+	if route == "" {
+		route = "unmatched"
+	}
+	code := strconv.Itoa(status)
+	httpRequestsTotal.WithLabelValues(route, method, code).Inc()
+	httpRequestDuration.WithLabelValues(route, method, code).Observe(seconds)
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithLogging_RecordsMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items/{id}", WithLogging(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	counter := httpRequestsTotal.WithLabelValues("/items/{id}", "GET", "418")
+	before := testutil.ToFloat64(counter)
+
+	for _, path := range []string{"/items/1", "/items/2"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Both paths share one series because the label is the route pattern
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Errorf("expected 2 requests recorded for the route, got %v", got)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	etlDocumentsFetched.Inc()
+
+	w := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := io.ReadAll(w.Body)
+	for _, name := range []string{
+		"proxy_etl_documents_fetched_total",
+		"proxy_etl_insert_failures_total",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("expected %s in /metrics output", name)
+		}
+	}
+}
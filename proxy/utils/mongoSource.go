@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoSource reads ingested documents from a MongoDB collection and keeps
+// their sync state on the documents themselves.
+type MongoSource struct {
+	Collection *mongo.Collection
+	// MaxAttempts is how many failures a document gets before it is
+	// dead-lettered (default 5).
+	MaxAttempts int
+}
+
+// mongoCursor adapts *mongo.Cursor to DocumentCursor.
+type mongoCursor struct {
+	*mongo.Cursor
+}
+
+func (c mongoCursor) Document() bson.Raw { return c.Current }
+
+func (m *MongoSource) Fetch(ctx context.Context, limit int, exclude []any) (DocumentCursor, error) {
+	filter := pendingFilter()
+	if len(exclude) > 0 {
+		filter["_id"] = bson.M{"$nin": exclude}
+	}
+
+	opts := options.Find().SetLimit(int64(limit))
+	cursor, err := m.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return mongoCursor{cursor}, nil
+}
+
+// Page returns pending documents in _id order. ObjectIDs are compared with
+// $gt so the _id index is used; other _id types go through $expr, which
+// compares across BSON types in the same order the sort uses.
+func (m *MongoSource) Page(ctx context.Context, after any, limit int) (DocumentCursor, error) {
+	filter := pendingFilter()
+	if after != nil {
+		if rv, ok := after.(bson.RawValue); ok {
+			if oid, ok := rv.ObjectIDOK(); ok {
+				after = oid
+			}
+		}
+		if _, ok := after.(primitive.ObjectID); ok {
+			filter["_id"] = bson.M{"$gt": after}
+		} else {
+			filter["$expr"] = bson.M{"$gt": bson.A{"$_id", after}}
+		}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := m.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return mongoCursor{cursor}, nil
+}
+
+func (m *MongoSource) Pending(ctx context.Context) (int64, error) {
+	return m.Collection.CountDocuments(ctx, pendingFilter())
+}
+
+// Ack marks ids processed with one unordered BulkWrite. If the write fails
+// as a whole, every id is reported.
+func (m *MongoSource) Ack(ctx context.Context, ids []primitive.ObjectID) map[int]error {
+	update := bson.M{"$set": bson.M{"status": statusProcessed}}
+	models := make([]mongo.WriteModel, len(ids))
+	for i, id := range ids {
+		models[i] = mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": id}).SetUpdate(update)
+	}
+
+	_, err := m.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err == nil {
+		return nil
+	}
+
+	failed := make(map[int]error)
+	var bwe mongo.BulkWriteException
+	if errors.As(err, &bwe) && bwe.WriteConcernError == nil && len(bwe.WriteErrors) > 0 {
+		for _, we := range bwe.WriteErrors {
+			failed[we.Index] = we
+		}
+		return failed
+	}
+	for i := range ids {
+		failed[i] = err
+	}
+	return failed
+}
+
+// Nack records each failure on its document with one unordered BulkWrite:
+// the reason and attempt time, and status quarantined, or failed with the
+// attempt count incremented and dead_letter once it reaches MaxAttempts.
+// Failed documents get pipeline updates so the new status can depend on the
+// stored attempt count. Documents that are no longer pending are left alone.
+func (m *MongoSource) Nack(ctx context.Context, failures []Failure) error {
+	maxAttempts := m.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5 // Default
+	}
+
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, len(failures))
+	for i, f := range failures {
+		filter := pendingFilter()
+		filter["_id"] = f.ID
+
+		var update any
+		if f.Quarantine {
+			update = bson.M{"$set": bson.M{
+				"status":          statusQuarantined,
+				"sync_error":      f.Reason,
+				"last_attempt_at": now,
+			}}
+		} else {
+			update = mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{
+					"sync_attempts":   bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$sync_attempts", 0}}, 1}},
+					"sync_error":      f.Reason,
+					"last_attempt_at": now,
+				}}},
+				{{Key: "$set", Value: bson.M{
+					"status": bson.M{"$cond": bson.A{
+						bson.M{"$gte": bson.A{"$sync_attempts", maxAttempts}}, statusDeadLetter, statusFailed,
+					}},
+				}}},
+			}
+		}
+		models[i] = mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update)
+	}
+
+	_, err := m.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
@@ -4,146 +4,547 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
-	"os"
-	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"logger"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ReadingService struct {
 	DB          *sql.DB
 	MongoClient *mongo.Client
+	Sync        SyncConfig
+	// Schemas validates payloads before insert; nil disables validation.
+	Schemas *SchemaRegistry
+	// Source is where documents are synced from; nil means the Mongo
+	// collection named in Sync.
+	Source Source
+
+	lastSync atomic.Int64 // unix nanoseconds of the last successful sync
+	stopping atomic.Bool
+	jobs     syncJobs
 }
 
-func (s *ReadingService) ReadingHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"placeholder": "reading placeholder still"})
+// Stop asks running and future syncs to finish after the current document,
+// so a shutdown never leaves a document inserted but not marked processed.
+func (s *ReadingService) Stop() {
+	s.stopping.Store(true)
 }
 
+// LastSync returns when the last sync completed successfully, or the zero
+// time if none has since startup.
+func (s *ReadingService) LastSync() time.Time {
+	if ns := s.lastSync.Load(); ns != 0 {
+		return time.Unix(0, ns).UTC()
+	}
+	return time.Time{}
+}
+
+// Wait blocks until background sync jobs have returned or ctx is done.
+func (s *ReadingService) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.jobs.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SyncReadingHandler starts a sync job and answers 202 with its ID; progress
+// is at /api/sync/jobs/{id}. With ?wait=true it runs the job inside the
+// request instead and answers with the finished job. Only one job runs at a
+// time, a second request gets 409 and the running job. ?trigger= (timer,
+// manual or api, the default) is recorded in etl_runs. ?dry_run=true answers
+// with a DryRunReport instead and writes nothing. It only accepts POST; main
+// wraps it in WithAuth.
 func (s *ReadingService) SyncReadingHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	if err := s.ensureReadingAnalyticsTable(); err != nil {
-		slog.Error("ETL_ERROR: Failed to create reading_analytics table", "error", err)
-		http.Error(w, "Failed to ensure database schema", 500)
+	if r.Method != http.MethodPost {
+		slog.WarnContext(ctx, "sync_method_not_allowed", "http_method", r.Method, "remote_ip", r.RemoteAddr)
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		s.dryRunHandler(w, r)
 		return
 	}
 
-	coll := s.getMongoCollection()
-	cursor, err := s.fetchIngestedDocuments(ctx, coll)
+	trigger, err := parseTrigger(r.URL.Query().Get("trigger"))
 	if err != nil {
-		slog.Error("ETL_ERROR: Failed to query Mongo", "error", err)
-		http.Error(w, "Failed to query Mongo", 500)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job, started := s.jobs.start(trigger)
+	if !started {
+		slog.WarnContext(ctx, "sync_already_running", "job_id", job.snapshot().ID)
+		writeJob(w, http.StatusConflict, job.snapshot())
 		return
 	}
-	defer cursor.Close(ctx)
 
-	processedCount := s.processDocuments(ctx, cursor, coll)
+	if r.URL.Query().Get("wait") == "true" {
+		s.runSync(ctx, job)
 
-	res := map[string]interface{}{
-		"service":         "reading-sync",
-		"status":          "success",
-		"processed_count": processedCount,
-		"timestamp":       time.Now().UTC(),
+		state := job.snapshot()
+		code := http.StatusOK
+		switch state.Status {
+		case JobFailed:
+			code = http.StatusInternalServerError
+		case JobInterrupted:
+			code = http.StatusServiceUnavailable
+		}
+		writeJob(w, code, state)
+		return
 	}
 
-	slog.Info("ETL_SUCCESS: Processed batch", "details", res)
+	// The job outlives the request but keeps its request ID for logging
+	bg := context.WithoutCancel(ctx)
+	s.jobs.wg.Go(func() { s.runSync(bg, job) })
 
+	state := job.snapshot()
+	w.Header().Set("Location", "/api/sync/jobs/"+state.ID)
+	writeJob(w, http.StatusAccepted, state)
+}
+
+// RunSync runs one sync job in the calling goroutine and returns it once
+// finished. If a job is already running it returns that one and false.
+func (s *ReadingService) RunSync(ctx context.Context, trigger string) (SyncJob, bool) {
+	job, started := s.jobs.start(trigger)
+	if !started {
+		return job.snapshot(), false
+	}
+	s.runSync(ctx, job)
+	return job.snapshot(), true
+}
+
+func writeJob(w http.ResponseWriter, code int, job SyncJob) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(res)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(job)
+}
+
+// runSync runs one ETL batch and records its progress and outcome on job
+// and, once finished, in etl_runs.
+func (s *ReadingService) runSync(ctx context.Context, job *syncJob) {
+	defer s.jobs.done(job)
+	defer func() { s.recordRun(ctx, job.snapshot()) }()
+	jobID := job.snapshot().ID
+	slog.InfoContext(ctx, "sync_job_started", "job_id", jobID)
+
+	interrupted, err := s.drain(ctx, job)
+	if errors.Is(err, errPostgresUnavailable) {
+		slog.ErrorContext(ctx, "ETL_ERROR: Postgres unavailable, sync stopped", "job_id", jobID, "error", err)
+		job.update(func(j *SyncJob) {
+			j.Errors = append(j.Errors, logger.ScrubString(err.Error()))
+		})
+		job.finish(JobFailed)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "ETL_ERROR: Failed to query Mongo", "job_id", jobID, "error", err)
+		job.update(func(j *SyncJob) {
+			j.Errors = append(j.Errors, "failed to query Mongo: "+logger.ScrubString(err.Error()))
+		})
+		job.finish(JobFailed)
+		return
+	}
+	if interrupted {
+		job.finish(JobInterrupted)
+		slog.WarnContext(ctx, "ETL_WARN: Sync interrupted by shutdown", "details", job.snapshot())
+		return
+	}
+
+	job.finish(JobSucceeded)
+	state := job.snapshot()
+	s.lastSync.Store(state.FinishedAt.UnixNano())
+	slog.InfoContext(ctx, "ETL_SUCCESS: Processed batch", "details", state)
+}
+
+// drain processes pages of BATCH_SIZE ingested documents until none are
+// left, SYNC_MAX_DURATION has passed or SYNC_MAX_DOCUMENTS were fetched.
+// Documents that failed in this run are excluded from later pages so they
+// cannot be fetched over and over. It records on job whether the backlog was
+// drained and how many documents remain.
+func (s *ReadingService) drain(ctx context.Context, job *syncJob) (bool, error) {
+	src := s.source()
+	batchSize := s.Sync.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100 // Default
+	}
+	var deadline time.Time
+	if s.Sync.MaxDuration > 0 {
+		deadline = time.Now().Add(s.Sync.MaxDuration)
+	}
+
+	var exclude []any
+	for {
+		if s.stopping.Load() {
+			return true, nil
+		}
+
+		limit := batchSize
+		if s.Sync.MaxDocuments > 0 {
+			limit = min(limit, s.Sync.MaxDocuments-job.snapshot().Fetched)
+		}
+		if limit <= 0 || (!deadline.IsZero() && time.Now().After(deadline)) {
+			break
+		}
+
+		cursor, err := src.Fetch(ctx, limit, exclude)
+		if err != nil {
+			return false, err
+		}
+		fetched, failed, interrupted, err := s.processDocuments(ctx, cursor, job)
+		cursor.Close(ctx)
+		if err != nil {
+			return false, err
+		}
+		exclude = append(exclude, failed...)
+		if interrupted {
+			return true, nil
+		}
+
+		// A short page means nothing else matched. The documents that failed
+		// in this run are reported as failed, not as remaining.
+		if fetched < limit {
+			s.setBacklog(job, true, 0)
+			return false, nil
+		}
+	}
+
+	// A budget ran out; count what is left for the report
+	remaining, err := src.Pending(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "ETL_WARN: Failed to count remaining documents", "error", err)
+		remaining = -1
+	}
+	s.setBacklog(job, false, remaining)
+	slog.InfoContext(ctx, "etl_budget_exhausted", "fetched", job.snapshot().Fetched, "remaining", remaining)
+	return false, nil
 }
 
-func (s *ReadingService) ensureReadingAnalyticsTable() error {
-	_, err := s.DB.Exec(`CREATE TABLE IF NOT EXISTS reading_analytics (
-		id SERIAL PRIMARY KEY,
-		mongo_id TEXT UNIQUE NOT NULL,
-		event_timestamp TIMESTAMPTZ,
-		source TEXT,
-		event_type TEXT,
-		payload JSONB,
-		meta JSONB,
-		created_at TIMESTAMPTZ DEFAULT NOW()
-	)`)
-	return err
+// setBacklog records the drain outcome. remaining is -1 when unknown.
+func (s *ReadingService) setBacklog(job *syncJob, drained bool, remaining int64) {
+	if remaining >= 0 {
+		etlBacklog.Set(float64(remaining))
+	}
+	job.update(func(j *SyncJob) {
+		j.Drained = drained
+		if remaining >= 0 {
+			j.Remaining = &remaining
+		}
+	})
 }
 
 func (s *ReadingService) getMongoCollection() *mongo.Collection {
-	dbName := os.Getenv("MONGO_DB_NAME")
-	collection := os.Getenv("MONGO_COLLECTION")
-	return s.MongoClient.Database(dbName).Collection(collection)
+	return s.MongoClient.Database(s.Sync.MongoDBName).Collection(s.Sync.MongoCollection)
+}
+
+// errPostgresUnavailable stops a sync when a chunk cannot be written because
+// Postgres does not answer; carrying on would only fail every other page.
+var errPostgresUnavailable = errors.New("postgres unavailable")
+
+// maxChunkSize keeps a chunk's insert under Postgres' 65535 bind parameters.
+const maxChunkSize = 10000
+
+// pendingDoc is a decoded document waiting for its chunk to be written.
+type pendingDoc struct {
+	id        primitive.ObjectID
+	timestamp any
+	source    string
+	eventType string
+	payload   []byte
+	meta      []byte
+}
+
+// newPendingDoc extracts the reading_analytics columns from doc. It fails if
+// payload or meta cannot be encoded as JSON.
+func newPendingDoc(id primitive.ObjectID, doc bson.M) (pendingDoc, error) {
+	p := pendingDoc{id: id, timestamp: doc["timestamp"]}
+	p.source, _ = doc["source"].(string)
+	p.eventType, _ = doc["event_type"].(string)
+
+	var err error
+	if p.payload, err = json.Marshal(doc["payload"]); err != nil {
+		return p, fmt.Errorf("encode payload: %w", err)
+	}
+	if p.meta, err = json.Marshal(doc["meta"]); err != nil {
+		return p, fmt.Errorf("encode meta: %w", err)
+	}
+	return p, nil
+}
+
+// rejection is a document that cannot be loaded. id is its raw _id, nil if
+// it has none. quarantine is set when the document was readable but its
+// payload cannot be loaded, as opposed to a document that failed to decode.
+type rejection struct {
+	id         any
+	reason     string
+	quarantine bool
 }
 
-func (s *ReadingService) fetchIngestedDocuments(ctx context.Context, coll *mongo.Collection) (*mongo.Cursor, error) {
-	filter := bson.M{"status": "ingested"}
+// decodeDocument converts raw into a pendingDoc and validates its payload
+// against the schema registry. hasSchema reports whether a schema applied.
+func (s *ReadingService) decodeDocument(raw bson.Raw) (p pendingDoc, hasSchema bool, rej *rejection) {
+	id, _ := rawID(raw)
 
-	batchSize := 100 // Default
-	if envSize := os.Getenv("BATCH_SIZE"); envSize != "" {
-		if val, err := strconv.Atoi(envSize); err == nil && val > 0 {
-			batchSize = val
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return p, false, &rejection{id: id, reason: "decode: " + err.Error()}
+	}
+	objID, ok := doc["_id"].(primitive.ObjectID)
+	if !ok {
+		return p, false, &rejection{id: id, reason: "decode: document missing ObjectID"}
+	}
+
+	p, err := newPendingDoc(objID, doc)
+	if err == nil {
+		if hasSchema, err = s.Schemas.Validate(p.source, p.eventType, p.payload); err != nil {
+			err = fmt.Errorf("schema %s/%s: %w", p.source, p.eventType, err)
 		}
 	}
+	if err != nil {
+		return p, hasSchema, &rejection{id: objID, reason: logger.ScrubString(err.Error()), quarantine: true}
+	}
+	return p, hasSchema, nil
+}
 
-	opts := options.Find().SetLimit(int64(batchSize))
-	return coll.Find(ctx, filter, opts)
+// recordDecoded counts the outcome of decodeDocument on job and logs a
+// rejection. It reports whether the document can be written.
+func (s *ReadingService) recordDecoded(ctx context.Context, job *syncJob, p pendingDoc, hasSchema bool, rej *rejection) bool {
+	switch {
+	case rej == nil:
+		job.update(func(j *SyncJob) {
+			if hasSchema {
+				j.Validation.Validated++
+			} else {
+				j.Validation.NoSchema++
+			}
+		})
+		return true
+	case rej.quarantine:
+		job.quarantine(p.id.Hex() + ": " + rej.reason)
+		slog.WarnContext(ctx, "ETL_WARN: Document quarantined", "id", p.id.Hex(), "source", p.source, "event_type", p.eventType, "error", rej.reason)
+	default:
+		etlDecodeFailures.Inc()
+		job.fail(rej.reason)
+		slog.WarnContext(ctx, "ETL_WARN: Failed to decode document", "error", rej.reason)
+	}
+	return false
 }
 
-func (s *ReadingService) processDocuments(ctx context.Context, cursor *mongo.Cursor, coll *mongo.Collection) int {
-	processedCount := 0
+// processDocuments buffers decoded documents and writes them in chunks of
+// Sync.ChunkSize, counting progress on job. It returns how many documents the
+// cursor yielded and the _ids of those that failed; documents that failed
+// on their own account are marked failed, and those with a payload that
+// does not match its schema quarantined, at the source before it returns. It
+// checks for Stop between documents and reports whether it was interrupted;
+// buffered documents that were not written yet stay ingested for the next
+// run. A cursor error is returned after the documents read before it are
+// written; if Postgres is unreachable it stops and returns that error.
+func (s *ReadingService) processDocuments(ctx context.Context, cursor DocumentCursor, job *syncJob) (int, []any, bool, error) {
+	chunkSize := s.Sync.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 100 // Default
+	}
+	chunk := make([]pendingDoc, 0, chunkSize)
+	fetched := 0
+	var failed []any
+	var failures []Failure
+	defer func() { s.nack(ctx, failures) }()
 
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err != nil {
-			slog.Warn("ETL_WARN: Failed to decode document", "error", err)
-			continue
+	write := func() error {
+		ids, docs, err := s.writeChunk(ctx, chunk, job)
+		failed = append(failed, ids...)
+		failures = append(failures, docs...)
+		chunk = chunk[:0]
+		return err
+	}
+
+	for {
+		if s.stopping.Load() {
+			return fetched, failed, true, nil
+		}
+		if !cursor.Next(ctx) {
+			break
 		}
+		fetched++
+		etlDocumentsFetched.Inc()
+		job.update(func(j *SyncJob) { j.Fetched++ })
 
-		objID, ok := doc["_id"].(primitive.ObjectID)
-		if !ok {
-			slog.Warn("ETL_WARN: Document missing ObjectID")
+		p, hasSchema, rej := s.decodeDocument(cursor.Document())
+		if !s.recordDecoded(ctx, job, p, hasSchema, rej) {
+			if rej.id != nil {
+				failed = append(failed, rej.id)
+				failures = append(failures, Failure{ID: rej.id, Reason: rej.reason, Quarantine: rej.quarantine})
+			}
 			continue
 		}
 
-		if err := s.insertIntoPostgres(doc, objID); err != nil {
-			slog.Error("ETL_ERROR: Failed to insert into Postgres", "id", objID.Hex(), "error", err)
-			continue
+		chunk = append(chunk, p)
+		if len(chunk) == chunkSize {
+			if err := write(); err != nil {
+				return fetched, failed, false, err
+			}
 		}
+	}
 
-		if err := s.updateMongoStatus(ctx, coll, objID); err != nil {
-			slog.Warn("ETL_WARN: Failed to update Mongo status", "id", objID.Hex(), "error", err)
-		} else {
-			processedCount++
+	if len(chunk) > 0 {
+		if err := write(); err != nil {
+			return fetched, failed, false, err
 		}
 	}
+	return fetched, failed, false, cursor.Err()
+}
 
-	return processedCount
+// rawID returns doc's _id, whatever its type, if it has one.
+func rawID(doc bson.Raw) (any, bool) {
+	v, err := doc.LookupErr("_id")
+	if err != nil {
+		return nil, false
+	}
+	return v, true
 }
 
-func (s *ReadingService) insertIntoPostgres(doc bson.M, objID primitive.ObjectID) error {
-	eventType, _ := doc["event_type"].(string)
-	source, _ := doc["source"].(string)
-	timestamp := doc["timestamp"]
+// writeChunk inserts a chunk in one transaction and then acks its documents
+// at the source in one round trip. Documents already in Postgres count as
+// skipped and are acked too. If the transaction fails while Postgres is
+// reachable, the documents are retried one by one so only the bad ones fail;
+// if Postgres is down, nothing is acked, the chunk stays ingested and an
+// error wrapping errPostgresUnavailable is returned. It returns the _ids that
+// failed, and the failures caused by the documents themselves.
+func (s *ReadingService) writeChunk(ctx context.Context, chunk []pendingDoc, job *syncJob) ([]any, []Failure, error) {
+	start := time.Now()
+	inserted, err := s.insertChunk(ctx, chunk)
+	elapsed := time.Since(start)
+	etlChunkDuration.Observe(elapsed.Seconds())
 
-	payloadJSON, _ := json.Marshal(doc["payload"])
-	metaJSON, _ := json.Marshal(doc["meta"])
+	var failedIDs []any
+	var failures []Failure
+	if err != nil {
+		slog.ErrorContext(ctx, "ETL_ERROR: Failed to insert chunk into Postgres", "size", len(chunk), "first_id", chunk[0].id.Hex(), "error", err)
+
+		if pingErr := s.DB.PingContext(ctx); pingErr != nil {
+			etlInsertFailures.Add(float64(len(chunk)))
+			msg := logger.ScrubString(err.Error())
+			for _, p := range chunk {
+				failedIDs = append(failedIDs, p.id)
+				job.fail(p.id.Hex() + ": insert: " + msg)
+			}
+			return failedIDs, nil, fmt.Errorf("%w: %v", errPostgresUnavailable, pingErr)
+		}
 
-	_, err := s.DB.Exec(
-		`INSERT INTO reading_analytics (mongo_id, event_timestamp, source, event_type, payload, meta, created_at) 
-		 VALUES ($1, $2, $3, $4, $5, $6, NOW())
-		 ON CONFLICT (mongo_id) DO NOTHING`,
-		objID.Hex(), timestamp, source, eventType, payloadJSON, metaJSON,
+		inserted = make(map[string]bool, len(chunk))
+		written := make([]pendingDoc, 0, len(chunk))
+		for _, p := range chunk {
+			if len(chunk) > 1 {
+				var ins map[string]bool
+				if ins, err = s.insertChunk(ctx, []pendingDoc{p}); err == nil {
+					maps.Copy(inserted, ins)
+					written = append(written, p)
+					continue
+				}
+			}
+			reason := "insert: " + logger.ScrubString(err.Error())
+			etlInsertFailures.Inc()
+			failedIDs = append(failedIDs, p.id)
+			failures = append(failures, Failure{ID: p.id, Reason: reason})
+			job.fail(p.id.Hex() + ": " + reason)
+		}
+		chunk = written
+		if len(chunk) == 0 {
+			return failedIDs, failures, nil
+		}
+	}
+
+	etlDocumentsInserted.Add(float64(len(inserted)))
+	etlDocumentsSkipped.Add(float64(len(chunk) - len(inserted)))
+	job.update(func(j *SyncJob) {
+		j.Inserted += len(inserted)
+		j.Skipped += len(chunk) - len(inserted)
+	})
+	slog.DebugContext(ctx, "etl_chunk_written",
+		"size", len(chunk),
+		"inserted", len(inserted),
+		"duration_ms", elapsed.Milliseconds(),
 	)
-	return err
+
+	ids := make([]primitive.ObjectID, len(chunk))
+	for i, p := range chunk {
+		ids[i] = p.id
+	}
+	failed := s.source().Ack(ctx, ids)
+	for i, err := range failed {
+		failedIDs = append(failedIDs, ids[i])
+		job.fail(ids[i].Hex() + ": ack: " + logger.ScrubString(err.Error()))
+		slog.WarnContext(ctx, "ETL_WARN: Failed to update Mongo status", "id", ids[i].Hex(), "error", err)
+	}
+	etlAckFailures.Add(float64(len(failed)))
+	etlDocumentsAcked.Add(float64(len(ids) - len(failed)))
+	job.update(func(j *SyncJob) { j.Acked += len(ids) - len(failed) })
+	return failedIDs, failures, nil
 }
 
-func (s *ReadingService) updateMongoStatus(ctx context.Context, coll *mongo.Collection, objID primitive.ObjectID) error {
-	update := bson.M{"$set": bson.M{"status": "processed"}}
-	_, err := coll.UpdateOne(ctx, bson.M{"_id": objID}, update)
-	return err
+// insertChunk writes the chunk with one multi-row INSERT in a transaction and
+// returns the mongo_ids that were new. Conflicting mongo_ids are left as they
+// are, so re-syncing a document is harmless.
+func (s *ReadingService) insertChunk(ctx context.Context, chunk []pendingDoc) (map[string]bool, error) {
+	const cols = 6
+	values := make([]string, 0, len(chunk))
+	args := make([]any, 0, len(chunk)*cols)
+	for i, p := range chunk {
+		n := i * cols
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, NOW())", n+1, n+2, n+3, n+4, n+5, n+6))
+		args = append(args, p.id.Hex(), p.timestamp, p.source, p.eventType, p.payload, p.meta)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`INSERT INTO reading_analytics (mongo_id, event_timestamp, source, event_type, payload, meta, created_at)
+		 VALUES `+strings.Join(values, ", ")+`
+		 ON CONFLICT (mongo_id) DO NOTHING
+		 RETURNING mongo_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	inserted := make(map[string]bool, len(chunk))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		inserted[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return inserted, tx.Commit()
 }
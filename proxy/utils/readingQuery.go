@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReadingLimit = 50
+	maxReadingLimit     = 500
+)
+
+// ReadingEvent is one row of reading_analytics as returned by /api/reading.
+type ReadingEvent struct {
+	ID             int64           `json:"id"`
+	MongoID        string          `json:"mongo_id"`
+	EventTimestamp time.Time       `json:"event_timestamp"`
+	Source         string          `json:"source"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Meta           json.RawMessage `json:"meta"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type readingPage struct {
+	Events     []ReadingEvent `json:"events"`
+	Count      int            `json:"count"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// readingQuery holds the parsed /api/reading parameters.
+type readingQuery struct {
+	Source    string
+	EventType string
+	From      time.Time // inclusive
+	To        time.Time // exclusive
+	Ascending bool
+	Limit     int
+	After     *readingCursor
+}
+
+// readingCursor is the (event_timestamp, id) of the last row of a page.
+// Clients treat the encoded form as opaque.
+type readingCursor struct {
+	Timestamp time.Time
+	ID        int64
+}
+
+func (c readingCursor) encode() string {
+	raw := c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeReadingCursor(s string) (*readingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("invalid cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &readingCursor{Timestamp: t, ID: n}, nil
+}
+
+func parseReadingQuery(values url.Values) (readingQuery, error) {
+	q := readingQuery{
+		Source:    values.Get("source"),
+		EventType: values.Get("event_type"),
+		Limit:     defaultReadingLimit,
+	}
+
+	for _, p := range []struct {
+		name string
+		dest *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if v := values.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s: want RFC 3339, e.g. 2026-01-04T12:00:00Z", p.name)
+			}
+			*p.dest = t
+		}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, errors.New("invalid range: from must be before to")
+	}
+
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return q, errors.New("invalid limit: must be a positive integer")
+		}
+		q.Limit = min(n, maxReadingLimit)
+	}
+
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, errors.New("invalid order: want asc or desc")
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		c, err := decodeReadingCursor(v)
+		if err != nil {
+			return q, err
+		}
+		q.After = c
+	}
+	return q, nil
+}
+
+// sql builds the keyset query. One extra row is fetched to tell whether
+// another page exists. Rows without an event_timestamp cannot be ordered and
+// are left out.
+func (q readingQuery) sql() (string, []any) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	where = append(where, "event_timestamp IS NOT NULL")
+	if q.Source != "" {
+		add("source = $%d", q.Source)
+	}
+	if q.EventType != "" {
+		add("event_type = $%d", q.EventType)
+	}
+	if !q.From.IsZero() {
+		add("event_timestamp >= $%d", q.From)
+	}
+	if !q.To.IsZero() {
+		add("event_timestamp < $%d", q.To)
+	}
+
+	direction, cmp := "DESC", "<"
+	if q.Ascending {
+		direction, cmp = "ASC", ">"
+	}
+	if q.After != nil {
+		args = append(args, q.After.Timestamp, q.After.ID)
+		where = append(where, fmt.Sprintf("(event_timestamp, id) %s ($%d, $%d)", cmp, len(args)-1, len(args)))
+	}
+
+	args = append(args, q.Limit+1)
+	query := fmt.Sprintf(`SELECT id, mongo_id, event_timestamp, source, event_type, payload, meta, created_at
+		FROM reading_analytics
+		WHERE %s
+		ORDER BY event_timestamp %s, id %s
+		LIMIT $%d`, strings.Join(where, " AND "), direction, direction, len(args))
+	return query, args
+}
+
+// ReadingHandler lists synced reading events, newest first, filtered by the
+// optional source, event_type, from and to (RFC 3339) parameters. Pages are
+// at most limit events (default 50, max 500); pass next_cursor back as
+// cursor to fetch the following page.
+func (s *ReadingService) ReadingHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseReadingQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.queryReadings(ctx, q)
+	if err != nil {
+		slog.ErrorContext(ctx, "reading_query_failed", "error", err)
+		http.Error(w, "Failed to query readings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (s *ReadingService) queryReadings(ctx context.Context, q readingQuery) (readingPage, error) {
+	query, args := q.sql()
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return readingPage{}, err
+	}
+	defer rows.Close()
+
+	events := make([]ReadingEvent, 0, q.Limit)
+	for rows.Next() {
+		var e ReadingEvent
+		var source, eventType sql.NullString
+		var payload, meta []byte
+		var createdAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.MongoID, &e.EventTimestamp, &source, &eventType, &payload, &meta, &createdAt); err != nil {
+			return readingPage{}, err
+		}
+		e.Source = source.String
+		e.EventType = eventType.String
+		e.Payload = jsonOrNull(payload)
+		e.Meta = jsonOrNull(meta)
+		e.CreatedAt = createdAt.Time
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return readingPage{}, err
+	}
+
+	page := readingPage{Events: events}
+	if len(events) > q.Limit {
+		page.Events = events[:q.Limit]
+		last := page.Events[q.Limit-1]
+		page.NextCursor = readingCursor{Timestamp: last.EventTimestamp, ID: last.ID}.encode()
+	}
+	page.Count = len(page.Events)
+	return page, nil
+}
+
+func jsonOrNull(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(b)
+}
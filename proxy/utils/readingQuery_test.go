@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var readingColumns = []string{"id", "mongo_id", "event_timestamp", "source", "event_type", "payload", "meta", "created_at"}
+
+func TestReadingHandler(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	service := &ReadingService{DB: db}
+	t1 := time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)
+	t2 := t1.Add(-time.Hour)
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("filters_and_next_cursor", func(t *testing.T) {
+		// limit=2 fetches 3 rows to detect the next page
+		mock.ExpectQuery(`FROM reading_analytics\s+WHERE event_timestamp IS NOT NULL AND source = \$1 AND event_type = \$2 AND event_timestamp >= \$3\s+ORDER BY event_timestamp DESC, id DESC\s+LIMIT \$4`).
+			WithArgs("kindle", "highlight", from, 3).
+			WillReturnRows(sqlmock.NewRows(readingColumns).
+				AddRow(3, "m3", t1, "kindle", "highlight", []byte(`{"page":10}`), []byte(`{"device":"k1"}`), t1).
+				AddRow(2, "m2", t2, "kindle", "highlight", nil, nil, t2).
+				AddRow(1, "m1", t2, "kindle", "highlight", nil, nil, t2))
+
+		req := httptest.NewRequest("GET", "/api/reading?source=kindle&event_type=highlight&from=2026-01-01T00:00:00Z&limit=2", nil)
+		w := httptest.NewRecorder()
+		service.ReadingHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var page readingPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if page.Count != 2 || len(page.Events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(page.Events))
+		}
+		if string(page.Events[0].Payload) != `{"page":10}` || string(page.Events[1].Meta) != "null" {
+			t.Errorf("unexpected payload/meta: %s %s", page.Events[0].Payload, page.Events[1].Meta)
+		}
+
+		cursor, err := decodeReadingCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("invalid next_cursor %q: %v", page.NextCursor, err)
+		}
+		if cursor.ID != 2 || !cursor.Timestamp.Equal(t2) {
+			t.Errorf("expected cursor at the last returned row, got %+v", cursor)
+		}
+	})
+
+	t.Run("cursor_continues_after_last_row", func(t *testing.T) {
+		cursor := readingCursor{Timestamp: t2, ID: 2}.encode()
+		mock.ExpectQuery(`WHERE event_timestamp IS NOT NULL AND \(event_timestamp, id\) < \(\$1, \$2\)`).
+			WithArgs(t2, int64(2), defaultReadingLimit+1).
+			WillReturnRows(sqlmock.NewRows(readingColumns).
+				AddRow(1, "m1", t2, "kindle", "highlight", nil, nil, t2))
+
+		req := httptest.NewRequest("GET", "/api/reading?cursor="+cursor, nil)
+		w := httptest.NewRecorder()
+		service.ReadingHandler(w, req)
+
+		var page readingPage
+		json.Unmarshal(w.Body.Bytes(), &page)
+		if w.Code != http.StatusOK || page.Count != 1 || page.NextCursor != "" {
+			t.Errorf("expected a final page of 1 event, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("ascending_order", func(t *testing.T) {
+		mock.ExpectQuery(`ORDER BY event_timestamp ASC, id ASC`).
+			WithArgs(maxReadingLimit + 1).
+			WillReturnRows(sqlmock.NewRows(readingColumns))
+
+		req := httptest.NewRequest("GET", "/api/reading?order=asc&limit=10000", nil)
+		w := httptest.NewRecorder()
+		service.ReadingHandler(w, req)
+
+		if w.Code != http.StatusOK || w.Body.String() != "{\"events\":[],\"count\":0}\n" {
+			t.Errorf("expected empty page, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("query_failure", func(t *testing.T) {
+		mock.ExpectQuery("FROM reading_analytics").WillReturnError(errors.New("connection reset"))
+
+		req := httptest.NewRequest("GET", "/api/reading", nil)
+		w := httptest.NewRecorder()
+		service.ReadingHandler(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+	}
+}
+
+func TestReadingHandler_BadRequests(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		query          string
+		expectedStatus int
+	}{
+		{name: "invalid from", method: "GET", query: "from=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "empty range", method: "GET", query: "from=2026-01-02T00:00:00Z&to=2026-01-01T00:00:00Z", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", method: "GET", query: "limit=-1", expectedStatus: http.StatusBadRequest},
+		{name: "invalid order", method: "GET", query: "order=sideways", expectedStatus: http.StatusBadRequest},
+		{name: "invalid cursor", method: "GET", query: "cursor=not-a-cursor", expectedStatus: http.StatusBadRequest},
+		{name: "wrong method", method: "POST", expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	// No query is expected, so any database access fails the test
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	service := &ReadingService{DB: db}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/reading?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			service.ReadingHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -2,14 +2,17 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
@@ -26,27 +29,17 @@ func TestSyncReadingHandler(t *testing.T) {
 	// Setup Mongo Mock
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
-	// Set Env Vars for the handler to read
-	os.Setenv("MONGO_DB_NAME", "testdb")
-	os.Setenv("MONGO_COLLECTION", "testcoll")
-	defer func() {
-		os.Unsetenv("MONGO_DB_NAME")
-		os.Unsetenv("MONGO_COLLECTION")
-	}()
+	syncConfig := SyncConfig{MongoDBName: "testdb", MongoCollection: "testcoll", BatchSize: 100}
 
 	mt.Run("success_sync_one_document", func(mt *mtest.T) {
 		// Prepare Service with mock DBs
 		service := &ReadingService{
 			DB:          db,
 			MongoClient: mt.Client,
+			Sync:        syncConfig,
 		}
 
-		// 1. Postgres: Create Table
-		// We match the prefix roughly or the main part
-		mock.ExpectExec("CREATE TABLE IF NOT EXISTS reading_analytics").
-			WillReturnResult(sqlmock.NewResult(0, 0))
-
-		// 2. Mongo: Find
+		// 1. Mongo: Find
 		objID := primitive.NewObjectID()
 		eventTime := "2026-01-04T12:00:00Z"
 		firstDoc := bson.D{
@@ -59,18 +52,21 @@ func TestSyncReadingHandler(t *testing.T) {
 			{Key: "meta", Value: bson.D{{Key: "host", Value: "localhost"}}},
 		}
 
-		// mtest mocks the response from the server.
+		// mtest mocks the response from the server. Cursor ID 0 means no
+		// further batches, so the sync does not issue a getMore before
+		// writing the chunk.
 		mt.AddMockResponses(mtest.CreateCursorResponse(
-			1,
+			0,
 			"testdb.testcoll",
 			mtest.FirstBatch,
 			firstDoc,
 		))
 
-		// 3. Postgres: Insert
-		// Expect an INSERT with 6 arguments:
+		// 2. Postgres: Insert in a transaction
+		// Expect an INSERT with 6 arguments per document:
 		// mongo_id, timestamp, source, event_type, payload, meta
-		mock.ExpectExec("INSERT INTO reading_analytics").
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").
 			WithArgs(
 				objID.Hex(),
 				eventTime,        // timestamp
@@ -79,16 +75,25 @@ func TestSyncReadingHandler(t *testing.T) {
 				sqlmock.AnyArg(), // payload (JSON)
 				sqlmock.AnyArg(), // meta (JSON)
 			).
-			WillReturnResult(sqlmock.NewResult(1, 1))
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(objID.Hex()))
+		mock.ExpectCommit()
 
-		// 4. Mongo: UpdateOne
+		// 3. Mongo: BulkWrite ack
 		mt.AddMockResponses(bson.D{
 			{Key: "ok", Value: 1},
 			{Key: "n", Value: 1},
 			{Key: "nModified", Value: 1},
 		})
+
+		// 4. Postgres: the run is recorded
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "timer", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
 		// --- EXECUTION ---
-		req := httptest.NewRequest("POST", "/api/sync/reading", nil)
+		ackedBefore := testutil.ToFloat64(etlDocumentsAcked)
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true&trigger=timer", nil)
 		w := httptest.NewRecorder()
 
 		service.SyncReadingHandler(w, req)
@@ -97,6 +102,12 @@ func TestSyncReadingHandler(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status 200, got %d", w.Code)
 		}
+		if got := testutil.ToFloat64(etlDocumentsAcked) - ackedBefore; got != 1 {
+			t.Errorf("expected 1 acked document recorded, got %v", got)
+		}
+		if service.LastSync().IsZero() {
+			t.Error("expected LastSync to be recorded after a successful sync")
+		}
 
 		// Verify Postgres expectations
 		if err := mock.ExpectationsWereMet(); err != nil {
@@ -104,35 +115,36 @@ func TestSyncReadingHandler(t *testing.T) {
 		}
 	})
 
-	mt.Run("respect_batch_size_env", func(mt *mtest.T) {
+	mt.Run("respect_batch_size", func(mt *mtest.T) {
 		// Set custom batch size
-		os.Setenv("BATCH_SIZE", "50")
-		defer os.Unsetenv("BATCH_SIZE")
+		batchConfig := syncConfig
+		batchConfig.BatchSize = 50
 
 		service := &ReadingService{
 			DB:          db,
 			MongoClient: mt.Client,
+			Sync:        batchConfig,
 		}
 
-		// 1. Postgres: Create Table
-		mock.ExpectExec("CREATE TABLE IF NOT EXISTS reading_analytics").
-			WillReturnResult(sqlmock.NewResult(0, 0))
-
-		// 2. Mongo: Find
+		// 1. Mongo: Find
 		// We expect the 'find' command to have a 'limit' field set to 50.
 		// mtest doesn't make it super easy to inspect the command options directly in the wrapper without using AddMockResponses,
 		// but we can trust that if the code path is hit, the value is used.
 		// For a strict unit test, we'd mock the Find options or inspect the command monitor, but here we'll verify the flow completes.
 
 		mt.AddMockResponses(mtest.CreateCursorResponse(
-			1,
+			0,
 			"testdb.testcoll",
 			mtest.FirstBatch,
 			bson.D{}, // Empty batch for this test, just checking query construction doesn't crash
 		))
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "api", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		// --- EXECUTION ---
-		req := httptest.NewRequest("POST", "/api/sync/reading", nil)
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
 		w := httptest.NewRecorder()
 
 		service.SyncReadingHandler(w, req)
@@ -142,10 +154,9 @@ func TestSyncReadingHandler(t *testing.T) {
 		}
 	})
 
-	mt.Run("log_error_on_create_table_failure", func(mt *mtest.T) {
+	mt.Run("log_error_on_mongo_query_failure", func(mt *mtest.T) {
 		// Capture logs
 		var buf bytes.Buffer
-		// Save original logger to restore later (although tests run in parallel, this might be risky if parallel=true, but here it is sequential per function usually unless t.Parallel() is called)
 		// Since we are modifying a global, we should be careful.
 		// For this specific test, we replace the default logger.
 		origLogger := slog.Default()
@@ -156,14 +167,24 @@ func TestSyncReadingHandler(t *testing.T) {
 		service := &ReadingService{
 			DB:          db,
 			MongoClient: mt.Client,
+			Sync:        syncConfig,
 		}
 
-		// 1. Postgres: Create Table FAILS
-		mock.ExpectExec("CREATE TABLE IF NOT EXISTS reading_analytics").
-			WillReturnError(errors.New("db connection lost"))
+		// 1. Mongo: Find FAILS
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    13,
+			Message: "not authorized",
+			Name:    "Unauthorized",
+		}))
+
+		// 2. Postgres: only the failed run is recorded
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "api", "failed", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		// --- EXECUTION ---
-		req := httptest.NewRequest("POST", "/api/sync/reading", nil)
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
 		w := httptest.NewRecorder()
 
 		service.SyncReadingHandler(w, req)
@@ -175,12 +196,525 @@ func TestSyncReadingHandler(t *testing.T) {
 
 		// Check if log contains the expected error message
 		logOutput := buf.String()
-		expectedLogPart := "ETL_ERROR: Failed to create reading_analytics table"
+		expectedLogPart := "ETL_ERROR: Failed to query Mongo"
 		if !bytes.Contains(buf.Bytes(), []byte(expectedLogPart)) {
 			t.Errorf("expected log to contain %q, got %q", expectedLogPart, logOutput)
 		}
 
-		// Verify Postgres expectations
+		// Nothing but the run record is written when Mongo is unavailable
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+	mt.Run("stop_interrupts_at_document_boundary", func(mt *mtest.T) {
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        syncConfig,
+		}
+		service.Stop()
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(
+			1,
+			"testdb.testcoll",
+			mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "status", Value: "ingested"}},
+		))
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "api", "interrupted", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status 503, got %d", w.Code)
+		}
+		if !bytes.Contains(w.Body.Bytes(), []byte(`"status":"interrupted"`)) {
+			t.Errorf("expected interrupted status, got %s", w.Body.String())
+		}
+		if !service.LastSync().IsZero() {
+			t.Error("an interrupted sync must not count as the last successful sync")
+		}
+
+		// The pending document is left for the next run; only the run is recorded
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+	mt.Run("rejects_non_post", func(mt *mtest.T) {
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        syncConfig,
+		}
+
+		req := httptest.NewRequest("GET", "/api/sync/reading", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status 405, got %d", w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != "POST" {
+			t.Errorf("expected Allow: POST, got %q", allow)
+		}
+	})
+	mt.Run("async_job_reports_progress", func(mt *mtest.T) {
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        syncConfig,
+		}
+
+		objID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(
+			0,
+			"testdb.testcoll",
+			mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: objID}, {Key: "status", Value: "ingested"}},
+		))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").WillReturnError(errors.New("disk full"))
+		mock.ExpectRollback()
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}) // marked failed
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "api", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest("POST", "/api/sync/reading", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected status 202, got %d", w.Code)
+		}
+		var started SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if loc := w.Header().Get("Location"); loc != "/api/sync/jobs/"+started.ID {
+			t.Errorf("expected Location for job %s, got %q", started.ID, loc)
+		}
+
+		if err := service.Wait(context.Background()); err != nil {
+			t.Fatalf("job did not finish: %v", err)
+		}
+
+		req = httptest.NewRequest("GET", "/api/sync/jobs/"+started.ID, nil)
+		req.SetPathValue("id", started.ID)
+		w = httptest.NewRecorder()
+		service.SyncJobHandler(w, req)
+
+		var job SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if job.Status != JobSucceeded || job.Fetched != 1 || job.Failed != 1 || job.Acked != 0 {
+			t.Errorf("unexpected job state: %+v", job)
+		}
+		if len(job.Errors) != 1 || !strings.Contains(job.Errors[0], objID.Hex()) {
+			t.Errorf("expected the insert error for %s, got %v", objID.Hex(), job.Errors)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+	mt.Run("fails_on_cursor_error", func(mt *mtest.T) {
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        syncConfig,
+		}
+
+		// The first batch arrives, then the getMore for the rest fails
+		objID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(1, "testdb.testcoll", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: objID}, {Key: "status", Value: "ingested"}}),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 6, Name: "HostUnreachable", Message: "connection reset"}),
+		)
+		// The document read before the error is still written
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(objID.Hex()))
+		mock.ExpectCommit()
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "api", "failed", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+		var job SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if job.Status != JobFailed || job.Drained || job.Inserted != 1 || job.Acked != 1 {
+			t.Errorf("expected a failed, undrained run, got %+v", job)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+	mt.Run("writes_in_chunks", func(mt *mtest.T) {
+		chunkConfig := syncConfig
+		chunkConfig.ChunkSize = 2
+
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        chunkConfig,
+		}
+
+		ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+		var docs []bson.D
+		for _, id := range ids {
+			docs = append(docs, bson.D{{Key: "_id", Value: id}, {Key: "status", Value: "ingested"}})
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch, docs...))
+
+		// First chunk: two documents in one statement, the second already synced
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO reading_analytics .* VALUES \(\$1, .*\), \(\$7, .*\)`).
+			WithArgs(ids[0].Hex(), nil, "", "", sqlmock.AnyArg(), sqlmock.AnyArg(),
+				ids[1].Hex(), nil, "", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(ids[0].Hex()))
+		mock.ExpectCommit()
+		ackOK := bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}
+		mt.AddMockResponses(ackOK) // both IDs acked in one round trip
+
+		// Second chunk: the remaining document
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").
+			WithArgs(ids[2].Hex(), nil, "", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(ids[2].Hex()))
+		mock.ExpectCommit()
+		mt.AddMockResponses(ackOK)
+
+		mock.ExpectExec("INSERT INTO etl_runs").WillReturnResult(sqlmock.NewResult(1, 1))
+
+		insertedBefore := testutil.ToFloat64(etlDocumentsInserted)
+		skippedBefore := testutil.ToFloat64(etlDocumentsSkipped)
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		var job SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if job.Inserted != 2 || job.Skipped != 1 || job.Acked != 3 || job.Failed != 0 {
+			t.Errorf("unexpected job state: %+v", job)
+		}
+		if got := testutil.ToFloat64(etlDocumentsInserted) - insertedBefore; got != 2 {
+			t.Errorf("expected 2 inserted documents counted, got %v", got)
+		}
+		if got := testutil.ToFloat64(etlDocumentsSkipped) - skippedBefore; got != 1 {
+			t.Errorf("expected 1 skipped document counted, got %v", got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+	mt.Run("reports_per_id_ack_failures", func(mt *mtest.T) {
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        syncConfig,
+		}
+
+		ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: ids[0]}}, bson.D{{Key: "_id", Value: ids[1]}}))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(ids[0].Hex()).AddRow(ids[1].Hex()))
+		mock.ExpectCommit()
+
+		// The second update in the bulk write fails
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "n", Value: 1},
+			{Key: "nModified", Value: 1},
+			{Key: "writeErrors", Value: bson.A{
+				bson.D{{Key: "index", Value: 1}, {Key: "code", Value: 2}, {Key: "errmsg", Value: "bad update"}},
+			}},
+		})
+		mock.ExpectExec("INSERT INTO etl_runs").WillReturnResult(sqlmock.NewResult(1, 1))
+
+		ackFailuresBefore := testutil.ToFloat64(etlAckFailures)
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		var job SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if job.Inserted != 2 || job.Acked != 1 || job.Failed != 1 {
+			t.Errorf("unexpected job state: %+v", job)
+		}
+		if len(job.Errors) != 1 || !strings.HasPrefix(job.Errors[0], ids[1].Hex()+": ack:") {
+			t.Errorf("expected the ack error for %s, got %v", ids[1].Hex(), job.Errors)
+		}
+		if got := testutil.ToFloat64(etlAckFailures) - ackFailuresBefore; got != 1 {
+			t.Errorf("expected 1 ack failure recorded, got %v", got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+	mt.Run("drains_pages_and_skips_failed_documents", func(mt *mtest.T) {
+		pageConfig := syncConfig
+		pageConfig.BatchSize = 1
+
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        pageConfig,
+		}
+
+		// Page 1: a full page whose only document fails to insert
+		failedID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: failedID}}))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").WillReturnError(errors.New("disk full"))
+		mock.ExpectRollback()
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}) // marked failed
+
+		// Page 2: nothing else is ingested
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch))
+		mock.ExpectExec("INSERT INTO etl_runs").WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		var job SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if !job.Drained || job.Remaining == nil || *job.Remaining != 0 || job.Failed != 1 {
+			t.Errorf("expected a drained run with the failed document counted as failed, got %+v", job)
+		}
+
+		// The second page must not fetch the failed document again
+		var finds []bson.Raw
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName == "find" {
+				finds = append(finds, e.Command)
+			}
+		}
+		if len(finds) != 2 {
+			t.Fatalf("expected 2 find commands, got %d", len(finds))
+		}
+		nin, err := finds[1].LookupErr("filter", "_id", "$nin")
+		if err != nil || !strings.Contains(nin.String(), failedID.Hex()) {
+			t.Errorf("expected the second page to exclude %s, got %v", failedID.Hex(), finds[1])
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+
+	mt.Run("stops_at_document_budget", func(mt *mtest.T) {
+		budgetConfig := syncConfig
+		budgetConfig.MaxDocuments = 1
+
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        budgetConfig,
+		}
+
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: id}}))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(id.Hex()))
+		mock.ExpectCommit()
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		// CountDocuments for the report
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+			bson.D{{Key: "n", Value: 41}}))
+		mock.ExpectExec("INSERT INTO etl_runs").WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		var job SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if job.Status != JobSucceeded || job.Drained || job.Fetched != 1 || job.Remaining == nil || *job.Remaining != 41 {
+			t.Errorf("expected a budget-limited run with 41 remaining, got %+v", job)
+		}
+		if got := testutil.ToFloat64(etlBacklog); got != 41 {
+			t.Errorf("expected backlog gauge 41, got %v", got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+	mt.Run("isolates_bad_document_in_failed_chunk", func(mt *mtest.T) {
+		chunkConfig := syncConfig
+		chunkConfig.ChunkSize = 2
+		chunkConfig.MaxAttempts = 3
+
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        chunkConfig,
+		}
+
+		good, bad := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: good}}, bson.D{{Key: "_id", Value: bad}}))
+
+		// The chunk fails as a whole, then each document is retried alone
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").WillReturnError(errors.New("invalid input syntax for type timestamp"))
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").WithArgs(good.Hex(), nil, "", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(good.Hex()))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").WithArgs(bad.Hex(), nil, "", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnError(errors.New("invalid input syntax for type timestamp"))
+		mock.ExpectRollback()
+
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}, // ack good
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}, // mark bad failed
+		)
+		mock.ExpectExec("INSERT INTO etl_runs").WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		var job SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if job.Inserted != 1 || job.Acked != 1 || job.Failed != 1 {
+			t.Errorf("expected only the bad document to fail, got %+v", job)
+		}
+
+		// The last update marks the bad document, with dead_letter after 3 attempts
+		var updates []bson.Raw
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName == "update" {
+				updates = append(updates, e.Command)
+			}
+		}
+		if len(updates) != 2 {
+			t.Fatalf("expected 2 update commands, got %d", len(updates))
+		}
+		mark := updates[1].String()
+		for _, want := range []string{bad.Hex(), "dead_letter", "sync_attempts", "invalid input syntax"} {
+			if !strings.Contains(mark, want) {
+				t.Errorf("expected %q in the failure update, got %s", want, mark)
+			}
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+	mt.Run("quarantines_invalid_payload", func(mt *mtest.T) {
+		dir := t.TempDir()
+		writeSchema(t, dir, "cpu_reading.json", `{"type": "object", "required": ["value"], "properties": {"value": {"type": "number"}}}`)
+		registry, err := LoadSchemaRegistry(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		service := &ReadingService{
+			DB:          db,
+			MongoClient: mt.Client,
+			Sync:        syncConfig,
+			Schemas:     registry,
+		}
+
+		good, bad, other := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: good}, {Key: "event_type", Value: "cpu_reading"}, {Key: "payload", Value: bson.D{{Key: "value", Value: 99}}}},
+			bson.D{{Key: "_id", Value: bad}, {Key: "event_type", Value: "cpu_reading"}, {Key: "payload", Value: bson.D{{Key: "value", Value: "high"}}}},
+			bson.D{{Key: "_id", Value: other}, {Key: "event_type", Value: "note"}},
+		))
+
+		// Only the valid and unchecked documents reach Postgres
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").
+			WithArgs(good.Hex(), nil, "", "cpu_reading", []byte(`{"value":99}`), sqlmock.AnyArg(),
+				other.Hex(), nil, "", "note", []byte("null"), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(good.Hex()).AddRow(other.Hex()))
+		mock.ExpectCommit()
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}, {Key: "nModified", Value: 2}}, // ack
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}, // quarantine
+		)
+		mock.ExpectExec("INSERT INTO etl_runs").WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+		w := httptest.NewRecorder()
+
+		service.SyncReadingHandler(w, req)
+
+		var job SyncJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		report := job.Validation
+		if report.Validated != 1 || report.NoSchema != 1 || report.Quarantined != 1 || job.Failed != 0 || job.Acked != 2 {
+			t.Errorf("unexpected job state: %+v", job)
+		}
+		if len(report.Failures) != 1 || !strings.Contains(report.Failures[0], bad.Hex()) || !strings.Contains(report.Failures[0], "/value") {
+			t.Errorf("expected the validation failure for %s, got %v", bad.Hex(), report.Failures)
+		}
+
+		var updates []bson.Raw
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName == "update" {
+				updates = append(updates, e.Command)
+			}
+		}
+		if len(updates) != 2 || !strings.Contains(updates[1].String(), "quarantined") {
+			t.Errorf("expected the bad document to be quarantined, got %v", updates)
+		}
+
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
 		}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Scheduler runs the reading sync on the SYNC_SCHEDULE cron expression
+// inside the proxy. A run that comes due while another job is running is
+// skipped rather than queued.
+type Scheduler struct {
+	service  *ReadingService
+	spec     string
+	schedule cron.Schedule
+	jitter   time.Duration
+	catchUp  bool
+
+	mu            sync.Mutex
+	nextRunAt     time.Time
+	lastRunAt     time.Time
+	lastJobID     string
+	lastSkippedAt time.Time
+}
+
+// ScheduleStatus is the scheduler's state as returned by
+// /api/sync/schedule. NextRunAt includes the jitter.
+type ScheduleStatus struct {
+	Enabled       bool       `json:"enabled"`
+	Schedule      string     `json:"schedule,omitempty"`
+	Jitter        string     `json:"jitter,omitempty"`
+	CatchUp       bool       `json:"catch_up"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastJob       *SyncJob   `json:"last_job,omitempty"`
+	LastSkippedAt *time.Time `json:"last_skipped_at,omitempty"`
+}
+
+// NewScheduler builds a scheduler from the service's SyncConfig.
+func NewScheduler(s *ReadingService) (*Scheduler, error) {
+	schedule, err := cron.ParseStandard(s.Sync.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		service:  s,
+		spec:     s.Sync.Schedule,
+		schedule: schedule,
+		jitter:   s.Sync.ScheduleJitter,
+		catchUp:  s.Sync.CatchUp,
+	}, nil
+}
+
+// Start runs the scheduler in the background until ctx is done. It counts as
+// a background job, so ReadingService.Wait also waits for it to return.
+func (sc *Scheduler) Start(ctx context.Context) {
+	sc.service.jobs.wg.Go(func() { sc.run(ctx) })
+}
+
+func (sc *Scheduler) run(ctx context.Context) {
+	slog.InfoContext(ctx, "sync_schedule_started", "schedule", sc.spec, "jitter", sc.jitter.String(), "catch_up", sc.catchUp)
+
+	last, err := sc.service.lastRunStart(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "sync_schedule_last_run_unknown", "error", err)
+	} else {
+		sc.mu.Lock()
+		sc.lastRunAt = last
+		sc.mu.Unlock()
+		if sc.catchUp && sc.missed(last, time.Now()) {
+			slog.InfoContext(ctx, "sync_schedule_catch_up", "last_run_at", last)
+			sc.fire(ctx)
+		}
+	}
+
+	sc.loop(ctx, time.Now())
+}
+
+// loop fires a sync at each slot after slot until ctx is done.
+func (sc *Scheduler) loop(ctx context.Context, slot time.Time) {
+	for {
+		// The next slot is worked out from now, so a run or host that stalls
+		// past a slot does not fire the missed ones back to back; missed runs
+		// are only made up once, at startup
+		if now := time.Now(); now.After(slot) {
+			slot = now
+		}
+		slot = sc.schedule.Next(slot)
+		at := slot
+		if sc.jitter > 0 {
+			at = at.Add(rand.N(sc.jitter))
+		}
+		sc.mu.Lock()
+		sc.nextRunAt = at
+		sc.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.InfoContext(ctx, "sync_schedule_stopped")
+			return
+		case <-timer.C:
+		}
+		sc.fire(ctx)
+	}
+}
+
+// minInterval returns the shortest gap between the next maxIntervalSlots
+// slots of schedule after from. Schedules such as "0 9,10 * * *" have uneven
+// gaps, so one pair is not enough.
+func minInterval(schedule cron.Schedule, from time.Time) time.Duration {
+	var shortest time.Duration
+	prev := schedule.Next(from)
+	for range maxIntervalSlots {
+		next := schedule.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(prev); shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+		prev = next
+	}
+	return shortest
+}
+
+// maxIntervalSlots bounds how many slots minInterval looks at.
+const maxIntervalSlots = 100
+
+// missed reports whether a scheduled run came due between the last run and
+// now. A sync that has never run counts as missed.
+func (sc *Scheduler) missed(last, now time.Time) bool {
+	return last.IsZero() || !sc.schedule.Next(last).After(now)
+}
+
+// fire starts a sync job unless one is already running or the proxy is
+// shutting down.
+func (sc *Scheduler) fire(ctx context.Context) {
+	if ctx.Err() != nil || sc.service.stopping.Load() {
+		return
+	}
+
+	now := time.Now().UTC()
+	job, started := sc.service.jobs.start(TriggerSchedule)
+	if !started {
+		slog.WarnContext(ctx, "sync_schedule_skipped", "running_job_id", job.snapshot().ID)
+		sc.mu.Lock()
+		sc.lastSkippedAt = now
+		sc.mu.Unlock()
+		return
+	}
+
+	sc.mu.Lock()
+	sc.lastRunAt = now
+	sc.lastJobID = job.snapshot().ID
+	sc.mu.Unlock()
+
+	// Shutdown stops the sync through ReadingService.Stop, not ctx
+	bg := context.WithoutCancel(ctx)
+	sc.service.jobs.wg.Go(func() { sc.service.runSync(bg, job) })
+}
+
+// Status returns the scheduler's state. A nil scheduler reports disabled.
+func (sc *Scheduler) Status() ScheduleStatus {
+	if sc == nil {
+		return ScheduleStatus{}
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	status := ScheduleStatus{
+		Enabled:       true,
+		Schedule:      sc.spec,
+		Jitter:        sc.jitter.String(),
+		CatchUp:       sc.catchUp,
+		NextRunAt:     timePtr(sc.nextRunAt),
+		LastRunAt:     timePtr(sc.lastRunAt),
+		LastSkippedAt: timePtr(sc.lastSkippedAt),
+	}
+	if job, ok := sc.service.jobs.get(sc.lastJobID); ok {
+		state := job.snapshot()
+		status.LastJob = &state
+	}
+	return status
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// ScheduleHandler reports when the scheduler runs next and when it last ran.
+func (sc *Scheduler) ScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sc.Status())
+}
+
+// lastRunStart returns when the most recent recorded sync started, or the
+// zero time if there is none.
+func (s *ReadingService) lastRunStart(ctx context.Context) (time.Time, error) {
+	var last sql.NullTime
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT max(started_at) FROM etl_runs WHERE pipeline = $1`, readingPipeline,
+	).Scan(&last)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return last.Time, nil
+}
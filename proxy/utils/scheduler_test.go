@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func newTestScheduler(t *testing.T, service *ReadingService, spec string) *Scheduler {
+	t.Helper()
+	service.Sync.Schedule = spec
+	service.Sync.CatchUp = true
+	sc, err := NewScheduler(service)
+	if err != nil {
+		t.Fatalf("NewScheduler() error = %v", err)
+	}
+	return sc
+}
+
+func TestScheduler_Missed(t *testing.T) {
+	sc := newTestScheduler(t, &ReadingService{}, "0 */12 * * *")
+	now := time.Date(2026, 1, 4, 13, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		last     time.Time
+		expected bool
+	}{
+		{name: "never_ran", expected: true},
+		{name: "ran_this_slot", last: time.Date(2026, 1, 4, 12, 5, 0, 0, time.UTC)},
+		{name: "missed_noon", last: time.Date(2026, 1, 4, 0, 10, 0, 0, time.UTC), expected: true},
+	}
+
+	for _, tt := range tests {
+		if got := sc.missed(tt.last, now); got != tt.expected {
+			t.Errorf("%s: missed() = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestScheduler_SkipsWhileRunning(t *testing.T) {
+	service := &ReadingService{}
+	sc := newTestScheduler(t, service, "@hourly")
+	running, _ := service.jobs.start(TriggerAPI)
+
+	sc.fire(context.Background())
+
+	status := sc.Status()
+	if status.LastSkippedAt == nil || status.LastRunAt != nil {
+		t.Errorf("expected the run to be skipped, got %+v", status)
+	}
+	if current, started := service.jobs.start(TriggerSchedule); started || current != running {
+		t.Error("expected the running job to be left alone")
+	}
+}
+
+func TestScheduler_LoopSkipsPastSlots(t *testing.T) {
+	service := &ReadingService{}
+	sc := newTestScheduler(t, service, "@hourly")
+	// A running job makes every fired run show up as skipped
+	service.jobs.start(TriggerAPI)
+
+	// As after a suspend: the previous slot is hours in the past
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sc.loop(ctx, start.Add(-3*time.Hour))
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for sc.Status().NextRunAt == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the next run to be scheduled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	status := sc.Status()
+	if status.LastSkippedAt != nil {
+		t.Errorf("expected no run for the missed slots, got one skipped at %v", status.LastSkippedAt)
+	}
+	if !status.NextRunAt.After(start) {
+		t.Errorf("expected the next run after %v, got %v", start, status.NextRunAt)
+	}
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("runs_missed_sync_at_start", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		service := &ReadingService{DB: db, MongoClient: mt.Client, Sync: SyncConfig{MongoDBName: "testdb", MongoCollection: "testcoll"}}
+		sc := newTestScheduler(t, service, "0 0 1 1 *")
+
+		mock.ExpectQuery(`SELECT max\(started_at\) FROM etl_runs`).
+			WithArgs("reading").
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(time.Now().AddDate(-2, 0, 0)))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch))
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "schedule", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sc.Start(ctx)
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if job := sc.Status().LastJob; job != nil && job.Status != JobRunning {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the catch-up run")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		if err := service.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+
+		status := sc.Status()
+		if status.LastJob.Trigger != TriggerSchedule || status.NextRunAt == nil {
+			t.Errorf("unexpected status: %+v", status)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+}
+
+func TestScheduleHandler(t *testing.T) {
+	var disabled *Scheduler
+	w := httptest.NewRecorder()
+	disabled.ScheduleHandler(w, httptest.NewRequest("GET", "/api/sync/schedule", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "{\"enabled\":false,\"catch_up\":false}\n" {
+		t.Errorf("unexpected response for a disabled scheduler: %d %s", w.Code, w.Body.String())
+	}
+
+	sc := newTestScheduler(t, &ReadingService{}, "0 */12 * * *")
+	w = httptest.NewRecorder()
+	sc.ScheduleHandler(w, httptest.NewRequest("GET", "/api/sync/schedule", nil))
+	var status ScheduleStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !status.Enabled || status.Schedule != "0 */12 * * *" || !status.CatchUp {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	w = httptest.NewRecorder()
+	sc.ScheduleHandler(w, httptest.NewRequest("POST", "/api/sync/schedule", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
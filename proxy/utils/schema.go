@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// SchemaRegistry holds the JSON Schemas that reading payloads are checked
+// against, keyed by source and event_type.
+type SchemaRegistry struct {
+	schemas map[schemaKey]*jsonschema.Schema
+}
+
+// schemaKey identifies a schema; an empty source applies to every source.
+type schemaKey struct {
+	source    string
+	eventType string
+}
+
+// LoadSchemaRegistry compiles every schema under dir. A file at
+// <source>/<event_type>.json applies to that source only, one at
+// <event_type>.json to any source without its own.
+func LoadSchemaRegistry(dir string) (*SchemaRegistry, error) {
+	r := &SchemaRegistry{schemas: make(map[schemaKey]*jsonschema.Schema)}
+	c := jsonschema.NewCompiler()
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+		parts := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, ".json")), "/")
+		var key schemaKey
+		switch len(parts) {
+		case 1:
+			key = schemaKey{eventType: parts[0]}
+		case 2:
+			key = schemaKey{source: parts[0], eventType: parts[1]}
+		default:
+			return fmt.Errorf("%s: want <event_type>.json or <source>/<event_type>.json", rel)
+		}
+
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		schema, err := c.Compile(abs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		r.schemas[key] = schema
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load schemas: %w", err)
+	}
+	return r, nil
+}
+
+// Len returns how many schemas are loaded.
+func (r *SchemaRegistry) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.schemas)
+}
+
+// Validate checks a JSON payload against the schema for source and
+// eventType. found is false when no schema applies; err lists every
+// violation otherwise.
+func (r *SchemaRegistry) Validate(source, eventType string, payload []byte) (found bool, err error) {
+	if r == nil {
+		return false, nil
+	}
+	schema, ok := r.schemas[schemaKey{source, eventType}]
+	if !ok {
+		if schema, ok = r.schemas[schemaKey{eventType: eventType}]; !ok {
+			return false, nil
+		}
+	}
+
+	v, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return true, err
+	}
+	err = schema.Validate(v)
+
+	var verr *jsonschema.ValidationError
+	if errors.As(err, &verr) {
+		var problems []string
+		for _, unit := range verr.BasicOutput().Errors {
+			if unit.Error != nil {
+				loc := unit.InstanceLocation
+				if loc == "" {
+					loc = "/"
+				}
+				problems = append(problems, loc+": "+unit.Error.String())
+			}
+		}
+		return true, errors.New(strings.Join(problems, "; "))
+	}
+	return true, err
+}
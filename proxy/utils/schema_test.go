@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSchema(t *testing.T, dir, rel, body string) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaRegistry_Validate(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "highlight.json", `{
+		"type": "object",
+		"required": ["text"],
+		"properties": {"text": {"type": "string"}}
+	}`)
+	writeSchema(t, dir, "kindle/highlight.json", `{
+		"type": "object",
+		"required": ["text", "page"],
+		"properties": {"text": {"type": "string"}, "page": {"type": "integer", "minimum": 1}}
+	}`)
+	writeSchema(t, dir, "README.md", "not a schema")
+
+	registry, err := LoadSchemaRegistry(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if registry.Len() != 2 {
+		t.Fatalf("expected 2 schemas, got %d", registry.Len())
+	}
+
+	tests := []struct {
+		name          string
+		source        string
+		eventType     string
+		payload       string
+		expectedFound bool
+		expectedError string
+	}{
+		{name: "source_specific_valid", source: "kindle", eventType: "highlight", payload: `{"text":"x","page":3}`, expectedFound: true},
+		{name: "source_specific_invalid", source: "kindle", eventType: "highlight", payload: `{"text":"x","page":0}`, expectedFound: true, expectedError: "/page"},
+		{name: "fallback_to_any_source", source: "web", eventType: "highlight", payload: `{"text":"x"}`, expectedFound: true},
+		{name: "fallback_invalid", source: "web", eventType: "highlight", payload: `{"text":42}`, expectedFound: true, expectedError: "/text"},
+		{name: "no_schema", source: "web", eventType: "bookmark", payload: `"anything"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := registry.Validate(tt.source, tt.eventType, []byte(tt.payload))
+			if found != tt.expectedFound {
+				t.Errorf("expected found %v, got %v", tt.expectedFound, found)
+			}
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error mentioning %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadSchemaRegistry_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "invalid_json", files: map[string]string{"highlight.json": "{"}},
+		{name: "invalid_schema", files: map[string]string{"highlight.json": `{"type": 5}`}},
+		{name: "too_deep", files: map[string]string{"a/b/highlight.json": `{}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for rel, body := range tt.files {
+				writeSchema(t, dir, rel, body)
+			}
+			if _, err := LoadSchemaRegistry(dir); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+
+	if _, err := LoadSchemaRegistry(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for a missing directory, got nil")
+	}
+}
+
+func TestSchemaRegistry_Nil(t *testing.T) {
+	var registry *SchemaRegistry
+	if found, err := registry.Validate("kindle", "highlight", []byte(`{}`)); found || err != nil {
+		t.Errorf("expected a nil registry to accept everything, got %v, %v", found, err)
+	}
+}
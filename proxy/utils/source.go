@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Source is where the reading sync pulls documents from and reports back
+// to. MongoSource is the production backend; SpoolSource reads NDJSON files
+// for offline imports and tests.
+type Source interface {
+	// Fetch returns up to limit documents that are ingested or failed,
+	// leaving out the _ids in exclude.
+	Fetch(ctx context.Context, limit int, exclude []any) (DocumentCursor, error)
+	// Pending counts the documents Fetch could return.
+	Pending(ctx context.Context) (int64, error)
+	// Page returns up to limit of the same documents that come after the
+	// _id after (nil for the first page) in the source's own stable order.
+	// It is for previews and changes nothing.
+	Page(ctx context.Context, after any, limit int) (DocumentCursor, error)
+	// Ack marks documents processed and returns the errors by index into
+	// ids.
+	Ack(ctx context.Context, ids []primitive.ObjectID) map[int]error
+	// Nack records documents the sync could not load.
+	Nack(ctx context.Context, failures []Failure) error
+}
+
+// DocumentCursor iterates over fetched documents, like *mongo.Cursor.
+type DocumentCursor interface {
+	Next(ctx context.Context) bool
+	Document() bson.Raw
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// Failure is a document that failed for reasons of its own, as opposed to an
+// unreachable database. A failed document is retried up to
+// SYNC_MAX_ATTEMPTS times, then dead-lettered; a quarantined one is not
+// retried until it is requeued.
+type Failure struct {
+	ID         any
+	Reason     string
+	Quarantine bool
+}
+
+// source returns the configured Source, or the Mongo collection named in
+// Sync.
+func (s *ReadingService) source() Source {
+	if s.Source != nil {
+		return s.Source
+	}
+	return &MongoSource{Collection: s.getMongoCollection(), MaxAttempts: s.Sync.MaxAttempts}
+}
+
+// mongoCollection returns the collection behind the source, or false if the
+// source is not MongoDB. The dead-letter endpoints and change streams query
+// the collection directly, so they need it.
+func (s *ReadingService) mongoCollection() (*mongo.Collection, bool) {
+	if s.Source != nil {
+		src, ok := s.Source.(*MongoSource)
+		if !ok {
+			return nil, false
+		}
+		return src.Collection, true
+	}
+	return s.getMongoCollection(), s.MongoClient != nil
+}
+
+// nack reports failures to the source and counts them. A source error is
+// logged; the documents stay pending and are retried by the next run.
+func (s *ReadingService) nack(ctx context.Context, failures []Failure) {
+	if len(failures) == 0 {
+		return
+	}
+	if err := s.source().Nack(ctx, failures); err != nil {
+		slog.WarnContext(ctx, "ETL_WARN: Failed to mark documents failed", "count", len(failures), "error", err)
+		return
+	}
+	for _, f := range failures {
+		if f.Quarantine {
+			etlDocumentsQuarantined.Inc()
+		} else {
+			etlDocumentsMarkedFailed.Inc()
+		}
+	}
+}
+
+// sliceCursor is a DocumentCursor over documents already in memory.
+type sliceCursor struct {
+	docs []bson.Raw
+	pos  int
+}
+
+func (c *sliceCursor) Next(context.Context) bool {
+	if c.pos >= len(c.docs) {
+		return false
+	}
+	c.pos++
+	return true
+}
+
+func (c *sliceCursor) Document() bson.Raw          { return c.docs[c.pos-1] }
+func (c *sliceCursor) Err() error                  { return nil }
+func (c *sliceCursor) Close(context.Context) error { return nil }
@@ -0,0 +1,357 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxSpoolLine bounds one NDJSON line, i.e. one document.
+const maxSpoolLine = 16 << 20
+
+// SpoolSource reads documents from the *.ndjson files in a directory, one
+// MongoDB Extended JSON document per line as written by mongoexport. Status
+// changes are written back to the files the way MongoSource updates the
+// documents, so an import can be re-run or resumed. Documents without an
+// _id are given one when the files are read, and it is saved on the first
+// Fetch so a later run sees the same one; Page and Pending leave the files
+// untouched.
+type SpoolSource struct {
+	Dir string
+	// MaxAttempts is how many failures a document gets before it is
+	// dead-lettered (default 5).
+	MaxAttempts int
+
+	mu     sync.Mutex
+	loaded bool
+	files  []*spoolFile
+	byID   map[string]*spoolEntry
+}
+
+type spoolFile struct {
+	path    string
+	entries []*spoolEntry
+	// assigned is set while the file has _ids that were not saved yet.
+	assigned bool
+}
+
+// spoolEntry is one line of a spool file. Lines that are not valid
+// Extended JSON keep their text and are written back unchanged.
+type spoolEntry struct {
+	file *spoolFile
+	doc  bson.D
+	text []byte
+}
+
+func (e *spoolEntry) pending() bool {
+	status, _ := lookup(e.doc, "status").(string)
+	return status == "" || status == statusIngested || status == statusFailed
+}
+
+func (sp *SpoolSource) Fetch(ctx context.Context, limit int, exclude []any) (DocumentCursor, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if err := sp.load(ctx); err != nil {
+		return nil, err
+	}
+	// Fetched documents may be written to Postgres under their _id, so it
+	// must not change on the next read
+	for _, f := range sp.files {
+		if f.assigned {
+			if err := f.write(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	skip := make(map[string]bool, len(exclude))
+	for _, id := range exclude {
+		skip[formatDocumentID(id)] = true
+	}
+
+	var docs []bson.Raw
+	for _, f := range sp.files {
+		for _, e := range f.entries {
+			if len(docs) == limit {
+				return &sliceCursor{docs: docs}, nil
+			}
+			if e.doc == nil || !e.pending() || skip[formatDocumentID(lookup(e.doc, "_id"))] {
+				continue
+			}
+			raw, err := bson.Marshal(e.doc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.path, err)
+			}
+			docs = append(docs, raw)
+		}
+	}
+	return &sliceCursor{docs: docs}, nil
+}
+
+// Page returns pending documents in file order, starting after the one with
+// the _id after.
+func (sp *SpoolSource) Page(ctx context.Context, after any, limit int) (DocumentCursor, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if err := sp.load(ctx); err != nil {
+		return nil, err
+	}
+
+	var docs []bson.Raw
+	found := after == nil
+	for _, f := range sp.files {
+		for _, e := range f.entries {
+			if len(docs) == limit {
+				return &sliceCursor{docs: docs}, nil
+			}
+			if e.doc == nil || !e.pending() {
+				continue
+			}
+			if !found {
+				found = formatDocumentID(lookup(e.doc, "_id")) == formatDocumentID(after)
+				continue
+			}
+			raw, err := bson.Marshal(e.doc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.path, err)
+			}
+			docs = append(docs, raw)
+		}
+	}
+	return &sliceCursor{docs: docs}, nil
+}
+
+func (sp *SpoolSource) Pending(ctx context.Context) (int64, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if err := sp.load(ctx); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, f := range sp.files {
+		for _, e := range f.entries {
+			if e.doc != nil && e.pending() {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// Ack sets status processed on ids and rewrites their files. If a file
+// cannot be written, its ids are reported.
+func (sp *SpoolSource) Ack(ctx context.Context, ids []primitive.ObjectID) map[int]error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	failed := make(map[int]error)
+	if err := sp.load(ctx); err != nil {
+		for i := range ids {
+			failed[i] = err
+		}
+		return failed
+	}
+
+	touched := make(map[*spoolFile][]int)
+	for i, id := range ids {
+		e, ok := sp.byID[id.Hex()]
+		if !ok {
+			failed[i] = errors.New("document not in spool")
+			continue
+		}
+		setField(&e.doc, "status", statusProcessed)
+		touched[e.file] = append(touched[e.file], i)
+	}
+
+	for f, idx := range touched {
+		if err := f.write(); err != nil {
+			for _, i := range idx {
+				failed[i] = err
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
+
+// Nack records each failure on its document as MongoSource does and
+// rewrites the affected files. Documents that are no longer pending are left
+// alone.
+func (sp *SpoolSource) Nack(ctx context.Context, failures []Failure) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if err := sp.load(ctx); err != nil {
+		return err
+	}
+	maxAttempts := sp.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5 // Default
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now().UTC())
+	touched := make(map[*spoolFile]bool)
+	for _, f := range failures {
+		e, ok := sp.byID[formatDocumentID(f.ID)]
+		if !ok || !e.pending() {
+			continue
+		}
+		setField(&e.doc, "sync_error", f.Reason)
+		setField(&e.doc, "last_attempt_at", now)
+		if f.Quarantine {
+			setField(&e.doc, "status", statusQuarantined)
+		} else {
+			attempts := toInt(lookup(e.doc, "sync_attempts")) + 1
+			setField(&e.doc, "sync_attempts", attempts)
+			status := statusFailed
+			if attempts >= maxAttempts {
+				status = statusDeadLetter
+			}
+			setField(&e.doc, "status", status)
+		}
+		touched[e.file] = true
+	}
+
+	var errs []error
+	for f := range touched {
+		errs = append(errs, f.write())
+	}
+	return errors.Join(errs...)
+}
+
+// load reads the spool files once, without writing them.
+func (sp *SpoolSource) load(ctx context.Context) error {
+	if sp.loaded {
+		return nil
+	}
+
+	if _, err := os.Stat(sp.Dir); err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(sp.Dir, "*.ndjson"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	sp.files = nil
+	sp.byID = make(map[string]*spoolEntry)
+	for _, path := range paths {
+		f, err := sp.readFile(ctx, path)
+		if err != nil {
+			return err
+		}
+		sp.files = append(sp.files, f)
+	}
+	sp.loaded = true
+	return nil
+}
+
+func (sp *SpoolSource) readFile(ctx context.Context, path string) (*spoolFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	f := &spoolFile{path: path}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxSpoolLine)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		e := &spoolEntry{file: f}
+		if err := bson.UnmarshalExtJSON(line, false, &e.doc); err != nil {
+			slog.WarnContext(ctx, "ETL_WARN: Skipping invalid spool line", "file", path, "line", n, "error", err)
+			e.doc, e.text = nil, bytes.Clone(line)
+		} else {
+			if lookup(e.doc, "_id") == nil {
+				e.doc = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, e.doc...)
+				f.assigned = true
+			}
+			sp.byID[formatDocumentID(lookup(e.doc, "_id"))] = e
+		}
+		f.entries = append(f.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// write replaces the file with its current entries, through a temporary
+// file so a crash never leaves it half written.
+func (f *spoolFile) write() error {
+	var buf bytes.Buffer
+	for _, e := range f.entries {
+		line := e.text
+		if e.doc != nil {
+			var err error
+			if line, err = bson.MarshalExtJSON(e.doc, false, false); err != nil {
+				return fmt.Errorf("%s: %w", f.path, err)
+			}
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp := filepath.Join(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp")
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	f.assigned = false
+	return nil
+}
+
+func lookup(doc bson.D, key string) any {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+func setField(doc *bson.D, key string, value any) {
+	for i := range *doc {
+		if (*doc)[i].Key == key {
+			(*doc)[i].Value = value
+			return
+		}
+	}
+	*doc = append(*doc, bson.E{Key: key, Value: value})
+}
+
+func toInt(v any) int {
+	switch n := v.(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
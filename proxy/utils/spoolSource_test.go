@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func writeSpool(t *testing.T, lines ...string) (dir, path string) {
+	t.Helper()
+	dir = t.TempDir()
+	path = filepath.Join(dir, "readings.ndjson")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, path
+}
+
+// readSpool returns the documents in path by line; invalid lines are nil.
+func readSpool(t *testing.T, path string) []bson.M {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []bson.M
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var doc bson.M
+		if err := bson.UnmarshalExtJSON([]byte(line), false, &doc); err != nil {
+			doc = nil
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func TestSpoolSource_SyncPipeline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	objID := primitive.NewObjectID()
+	processedID := primitive.NewObjectID()
+	dir, path := writeSpool(t,
+		`{"_id":{"$oid":"`+objID.Hex()+`"},"status":"ingested","source":"test-agent","event_type":"cpu_reading","timestamp":"2026-01-04T12:00:00Z","payload":{"value":99}}`,
+		`{"source":"test-agent","event_type":"cpu_reading","timestamp":"2026-01-04T12:01:00Z","payload":{"value":98}}`,
+		`{"_id":{"$oid":"`+processedID.Hex()+`"},"status":"processed","source":"test-agent","event_type":"cpu_reading"}`,
+		`{"_id":"legacy-1","status":"ingested"}`,
+		`not json`,
+		``,
+	)
+
+	service := &ReadingService{
+		DB:     db,
+		Source: &SpoolSource{Dir: dir},
+		Sync:   SyncConfig{ChunkSize: 10},
+	}
+
+	// Both pending ObjectID documents go into one chunk
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO reading_analytics .* VALUES \(\$1, .*\), \(\$7, .*\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(objID.Hex()).AddRow("assigned"))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO etl_runs").
+		WithArgs(sqlmock.AnyArg(), "reading", "api", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	req := httptest.NewRequest("POST", "/api/sync/reading?wait=true", nil)
+	w := httptest.NewRecorder()
+	service.SyncReadingHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+	}
+
+	docs := readSpool(t, path)
+	if len(docs) != 5 {
+		t.Fatalf("expected 5 lines written back, got %d", len(docs))
+	}
+	wantStatus := []string{"processed", "processed", "processed", "failed"}
+	for i, want := range wantStatus {
+		if got := docs[i]["status"]; got != want {
+			t.Errorf("line %d: expected status %q, got %v", i+1, want, got)
+		}
+	}
+	if _, ok := docs[1]["_id"].(primitive.ObjectID); !ok {
+		t.Errorf("expected an ObjectID to be assigned to line 2, got %v", docs[1]["_id"])
+	}
+	if got := toInt(docs[3]["sync_attempts"]); got != 1 {
+		t.Errorf("expected sync_attempts 1 on the legacy document, got %d", got)
+	}
+	if docs[4] != nil {
+		t.Errorf("expected the invalid line to be kept as is, got %v", docs[4])
+	}
+
+	// A second run finds only the failed document
+	pending, err := service.Source.Pending(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 1 {
+		t.Errorf("expected 1 pending document, got %d", pending)
+	}
+}
+
+func TestSpoolSource_Nack(t *testing.T) {
+	failedID, quarantineID, doneID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	dir, path := writeSpool(t,
+		`{"_id":{"$oid":"`+failedID.Hex()+`"},"status":"failed","sync_attempts":1}`,
+		`{"_id":{"$oid":"`+quarantineID.Hex()+`"},"status":"ingested"}`,
+		`{"_id":{"$oid":"`+doneID.Hex()+`"},"status":"processed"}`,
+	)
+	src := &SpoolSource{Dir: dir, MaxAttempts: 2}
+	ctx := context.Background()
+
+	if _, err := src.Pending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err := src.Nack(ctx, []Failure{
+		{ID: failedID, Reason: "insert: disk full"},
+		{ID: quarantineID, Reason: "schema: missing value", Quarantine: true},
+		{ID: doneID, Reason: "late failure"},
+	})
+	if err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		status   string
+		attempts int
+		reason   any
+	}{
+		{"dead_lettered_at_max_attempts", statusDeadLetter, 2, "insert: disk full"},
+		{"quarantined", statusQuarantined, 0, "schema: missing value"},
+		{"processed_left_alone", statusProcessed, 0, nil},
+	}
+
+	docs := readSpool(t, path)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := docs[i]["status"]; got != tt.status {
+				t.Errorf("expected status %q, got %v", tt.status, got)
+			}
+			if got := toInt(docs[i]["sync_attempts"]); got != tt.attempts {
+				t.Errorf("expected sync_attempts %d, got %d", tt.attempts, got)
+			}
+			if got := docs[i]["sync_error"]; got != tt.reason {
+				t.Errorf("expected sync_error %v, got %v", tt.reason, got)
+			}
+		})
+	}
+}
+
+func TestSpoolSource_AssignedIDsAreStable(t *testing.T) {
+	dir, _ := writeSpool(t, `{"status":"ingested","source":"test-agent"}`)
+	ctx := context.Background()
+
+	fetchID := func() primitive.ObjectID {
+		cursor, err := (&SpoolSource{Dir: dir}).Fetch(ctx, 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cursor.Next(ctx) {
+			t.Fatal("expected a document")
+		}
+		return cursor.Document().Lookup("_id").ObjectID()
+	}
+
+	first, second := fetchID(), fetchID()
+	if first.IsZero() || first != second {
+		t.Errorf("expected the same assigned _id on every read, got %s and %s", first.Hex(), second.Hex())
+	}
+
+	ack := (&SpoolSource{Dir: dir}).Ack(ctx, []primitive.ObjectID{first, primitive.NewObjectID()})
+	if len(ack) != 1 || ack[1] == nil {
+		t.Errorf("expected only the unknown id to fail, got %v", ack)
+	}
+}
+
+func TestSpoolSource_DryRunIsReadOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	dir, path := writeSpool(t,
+		`{"status":"ingested","source":"test-agent"}`,
+		`{"_id":{"$oid":"`+primitive.NewObjectID().Hex()+`"},"status":"ingested"}`,
+		`{"_id":{"$oid":"`+primitive.NewObjectID().Hex()+`"}}`,
+	)
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &ReadingService{DB: db, Source: &SpoolSource{Dir: dir}, Sync: SyncConfig{BatchSize: 2, ChunkSize: 10}}
+	mock.ExpectQuery("SELECT mongo_id FROM reading_analytics").
+		WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}))
+
+	report, err := service.DryRun(context.Background())
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	if report.Pending != 3 || report.Fetched != 3 || report.WouldInsert != 3 {
+		t.Errorf("unexpected counts: %+v", report)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("expected the spool to be left as is, got:\n%s", after)
+	}
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	streamMinBackoff = 5 * time.Second
+	streamMaxBackoff = 5 * time.Minute
+)
+
+// Server error codes that decide how a failed change stream is handled.
+const (
+	// codeChangeStreamUnsupported: $changeStream needs a replica set.
+	codeChangeStreamUnsupported = 40573
+	// codeChangeStreamHistoryLost: the resume token fell off the oplog.
+	codeChangeStreamHistoryLost = 286
+	// codeInvalidResumeToken: the resume token cannot be used.
+	codeInvalidResumeToken = 260
+)
+
+// errStreamClosed is returned by watch when the server ends the change
+// stream without an error, as it does when the collection is dropped or
+// renamed.
+var errStreamClosed = errors.New("change stream closed")
+
+// StartStream runs the streaming sync in the background until ctx is done.
+// Like the scheduler, it counts as a background job for Wait.
+func (s *ReadingService) StartStream(ctx context.Context) {
+	s.jobs.wg.Go(func() { s.stream(ctx) })
+}
+
+// stream keeps a change stream open on the collection and syncs each
+// ingested insert as it arrives. Failed streams are reopened from the saved
+// resume token with backoff, and streams the server closed are reopened
+// from scratch. If the deployment does not support change
+// streams, or the source is not MongoDB, it polls every
+// SYNC_STREAM_POLL_INTERVAL instead.
+func (s *ReadingService) stream(ctx context.Context) {
+	if _, ok := s.mongoCollection(); !ok {
+		slog.WarnContext(ctx, "sync_stream_unavailable", "reason", "source is not MongoDB", "poll_interval", s.Sync.StreamPollInterval.String())
+		s.poll(ctx)
+		return
+	}
+
+	backoff := streamMinBackoff
+	for ctx.Err() == nil && !s.stopping.Load() {
+		events, err := s.watch(ctx)
+		if ctx.Err() != nil || s.stopping.Load() {
+			break
+		}
+		if events > 0 {
+			backoff = streamMinBackoff
+		}
+
+		var serverErr mongo.ServerError
+		switch {
+		case errors.As(err, &serverErr) && serverErr.HasErrorCode(codeChangeStreamUnsupported):
+			slog.WarnContext(ctx, "sync_stream_unavailable", "error", err, "poll_interval", s.Sync.StreamPollInterval.String())
+			s.poll(ctx)
+			return
+		case errors.As(err, &serverErr) && (serverErr.HasErrorCode(codeChangeStreamHistoryLost) || serverErr.HasErrorCode(codeInvalidResumeToken)):
+			// Start over; the catch-up run picks up what the stream missed
+			slog.WarnContext(ctx, "sync_stream_history_lost", "error", err)
+			if err := s.clearResumeToken(ctx); err != nil {
+				slog.ErrorContext(ctx, "sync_stream_checkpoint_failed", "error", err)
+			} else {
+				continue
+			}
+		case errors.Is(err, errStreamClosed):
+			// The saved token comes before the invalidate, so resuming from
+			// it would close the stream again; the catch-up run covers the gap
+			if err := s.clearResumeToken(ctx); err != nil {
+				slog.ErrorContext(ctx, "sync_stream_checkpoint_failed", "error", err)
+			}
+		}
+
+		slog.ErrorContext(ctx, "sync_stream_failed", "error", err, "retry_in", backoff.String())
+		if !sleepContext(ctx, backoff) {
+			break
+		}
+		backoff = min(backoff*2, streamMaxBackoff)
+	}
+	etlStreamMode.Reset()
+	slog.InfoContext(ctx, "sync_stream_stopped")
+}
+
+// watch opens the change stream and processes events until ctx is done, the
+// stream fails or closes, or an event cannot be written to Postgres,
+// returning how many events it processed. Without a saved resume token it first runs a
+// catch-up sync for documents ingested before the stream opened; events for
+// documents that run already synced are skipped as duplicates.
+func (s *ReadingService) watch(ctx context.Context) (int, error) {
+	token, err := s.loadResumeToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"operationType":       "insert",
+		"fullDocument.status": statusIngested,
+	}}}}
+	opts := options.ChangeStream()
+	if token != nil {
+		opts.SetResumeAfter(token)
+	}
+	coll, _ := s.mongoCollection()
+	cs, err := coll.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cs.Close(context.WithoutCancel(ctx))
+
+	etlStreamMode.Reset()
+	etlStreamMode.WithLabelValues("streaming").Set(1)
+	slog.InfoContext(ctx, "sync_stream_started", "resumed", token != nil)
+	if token == nil {
+		s.runStreamJob(ctx)
+	}
+
+	events := 0
+	for cs.Next(ctx) {
+		if s.stopping.Load() {
+			return events, nil
+		}
+
+		// A started event finishes even if ctx is cancelled meanwhile. If it
+		// could not be written the checkpoint stays before it, so the
+		// reopened stream delivers it again.
+		ectx := context.WithoutCancel(ctx)
+		if doc, ok := cs.Current.Lookup("fullDocument").DocumentOK(); ok {
+			if err := s.processEvent(ectx, doc); err != nil {
+				return events, err
+			}
+		}
+		events++
+		if err := s.saveResumeToken(ectx, cs.ResumeToken()); err != nil {
+			slog.ErrorContext(ctx, "sync_stream_checkpoint_failed", "error", err)
+		}
+	}
+	if ctx.Err() != nil || s.stopping.Load() {
+		return events, nil
+	}
+	if err := cs.Err(); err != nil {
+		return events, err
+	}
+	return events, errStreamClosed
+}
+
+// processEvent syncs the full document of one insert event: it is inserted
+// and acked, or marked failed or quarantined, as in a batch sync. It returns
+// an error if Postgres is unavailable and the document was left as it was.
+func (s *ReadingService) processEvent(ctx context.Context, doc bson.Raw) error {
+	etlStreamEvents.Inc()
+	etlDocumentsFetched.Inc()
+	job := &syncJob{state: SyncJob{Trigger: TriggerStream, Fetched: 1}}
+
+	p, hasSchema, rej := s.decodeDocument(doc)
+	if !s.recordDecoded(ctx, job, p, hasSchema, rej) {
+		if rej.id != nil {
+			s.nack(ctx, []Failure{{ID: rej.id, Reason: rej.reason, Quarantine: rej.quarantine}})
+		}
+		return nil
+	}
+
+	_, failures, err := s.writeChunk(ctx, []pendingDoc{p}, job)
+	if err != nil {
+		return err
+	}
+	s.nack(ctx, failures)
+	if state := job.snapshot(); state.Acked > 0 {
+		s.lastSync.Store(time.Now().UnixNano())
+	}
+	return nil
+}
+
+// poll is the fallback when change streams are unavailable: a sync run
+// every SYNC_STREAM_POLL_INTERVAL until ctx is done.
+func (s *ReadingService) poll(ctx context.Context) {
+	etlStreamMode.Reset()
+	etlStreamMode.WithLabelValues("polling").Set(1)
+
+	ticker := time.NewTicker(s.Sync.StreamPollInterval)
+	defer ticker.Stop()
+	for {
+		s.runStreamJob(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runStreamJob runs a batch sync with trigger stream, unless another job is
+// running or the proxy is shutting down.
+func (s *ReadingService) runStreamJob(ctx context.Context) {
+	if ctx.Err() != nil || s.stopping.Load() {
+		return
+	}
+	job, started := s.jobs.start(TriggerStream)
+	if !started {
+		return
+	}
+	s.runSync(context.WithoutCancel(ctx), job)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// loadResumeToken returns the saved resume token, or nil if there is none.
+func (s *ReadingService) loadResumeToken(ctx context.Context) (bson.Raw, error) {
+	var token []byte
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT resume_token FROM sync_checkpoints WHERE pipeline = $1`, readingPipeline,
+	).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bson.Raw(token), nil
+}
+
+func (s *ReadingService) saveResumeToken(ctx context.Context, token bson.Raw) error {
+	if token == nil {
+		return nil
+	}
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO sync_checkpoints (pipeline, resume_token, updated_at) VALUES ($1, $2, NOW())
+		 ON CONFLICT (pipeline) DO UPDATE SET resume_token = EXCLUDED.resume_token, updated_at = EXCLUDED.updated_at`,
+		readingPipeline, []byte(token),
+	)
+	return err
+}
+
+func (s *ReadingService) clearResumeToken(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM sync_checkpoints WHERE pipeline = $1`, readingPipeline)
+	return err
+}
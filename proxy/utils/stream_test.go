@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestWatch(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	syncConfig := SyncConfig{MongoDBName: "testdb", MongoCollection: "testcoll"}
+
+	mt.Run("syncs_events_and_saves_token", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		service := &ReadingService{DB: db, MongoClient: mt.Client, Sync: syncConfig}
+
+		objID := primitive.NewObjectID()
+		token := bson.D{{Key: "_data", Value: "8263A1"}}
+		event := bson.D{
+			{Key: "_id", Value: token},
+			{Key: "operationType", Value: "insert"},
+			{Key: "fullDocument", Value: bson.D{
+				{Key: "_id", Value: objID},
+				{Key: "status", Value: "ingested"},
+				{Key: "source", Value: "kindle"},
+				{Key: "event_type", Value: "highlight"},
+			}},
+		}
+		tokenBytes, _ := bson.Marshal(token)
+
+		// No saved token: the stream opens, then a catch-up run finds nothing
+		mock.ExpectQuery("SELECT resume_token FROM sync_checkpoints").WithArgs("reading").WillReturnRows(sqlmock.NewRows([]string{"resume_token"}))
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, "testdb.testcoll", mtest.FirstBatch))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch))
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "stream", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		// One insert event: written, acked and checkpointed
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, "testdb.testcoll", mtest.NextBatch, event))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO reading_analytics").
+			WillReturnRows(sqlmock.NewRows([]string{"mongo_id"}).AddRow(objID.Hex()))
+		mock.ExpectCommit()
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+		mock.ExpectExec("INSERT INTO sync_checkpoints").
+			WithArgs("reading", tokenBytes).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		// Then the oplog rolls over
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: codeChangeStreamHistoryLost, Message: "history lost"}))
+
+		eventsBefore := testutil.ToFloat64(etlStreamEvents)
+		events, err := service.watch(context.Background())
+
+		var serverErr mongo.ServerError
+		if !errors.As(err, &serverErr) || !serverErr.HasErrorCode(codeChangeStreamHistoryLost) {
+			t.Errorf("expected the history lost error, got %v", err)
+		}
+		if events != 1 || testutil.ToFloat64(etlStreamEvents)-eventsBefore != 1 {
+			t.Errorf("expected 1 event, got %d", events)
+		}
+		if service.LastSync().IsZero() {
+			t.Error("expected LastSync to be recorded after a streamed document was synced")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+
+	mt.Run("keeps_checkpoint_when_postgres_is_down", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		service := &ReadingService{DB: db, MongoClient: mt.Client, Sync: syncConfig}
+
+		saved, _ := bson.Marshal(bson.D{{Key: "_data", Value: "8263A0"}})
+		event := bson.D{
+			{Key: "_id", Value: bson.D{{Key: "_data", Value: "8263A1"}}},
+			{Key: "operationType", Value: "insert"},
+			{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "status", Value: "ingested"}}},
+		}
+
+		// Resuming from a saved token, so there is no catch-up run
+		mock.ExpectQuery("SELECT resume_token FROM sync_checkpoints").WithArgs("reading").
+			WillReturnRows(sqlmock.NewRows([]string{"resume_token"}).AddRow(saved))
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(1, "testdb.testcoll", mtest.FirstBatch),
+			mtest.CreateCursorResponse(1, "testdb.testcoll", mtest.NextBatch, event),
+		)
+
+		// The insert fails and the ping confirms the outage; no
+		// sync_checkpoints write may follow
+		mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+		events, err := service.watch(context.Background())
+
+		if !errors.Is(err, errPostgresUnavailable) {
+			t.Errorf("expected errPostgresUnavailable, got %v", err)
+		}
+		if events != 0 {
+			t.Errorf("expected the event not to count as processed, got %d", events)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled Postgres expectations: %s", err)
+		}
+	})
+}
+
+func TestStream_ReopensClosedStream(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("collection_dropped", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		service := &ReadingService{DB: db, MongoClient: mt.Client, Sync: SyncConfig{
+			MongoDBName: "testdb", MongoCollection: "testcoll", StreamPollInterval: time.Hour,
+		}}
+
+		saved, _ := bson.Marshal(bson.D{{Key: "_data", Value: "8263A0"}})
+		mock.ExpectQuery("SELECT resume_token FROM sync_checkpoints").WithArgs("reading").
+			WillReturnRows(sqlmock.NewRows([]string{"resume_token"}).AddRow(saved))
+		// The server ends the stream without an error, as after an invalidate
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch))
+		mock.ExpectExec("DELETE FROM sync_checkpoints").WithArgs("reading").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		service.StartStream(ctx)
+		deadline := time.Now().Add(2 * time.Second)
+		for mock.ExpectationsWereMet() != nil {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for the stream to be reset: %v", mock.ExpectationsWereMet())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		cancel()
+		if err := service.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	})
+}
+
+func TestStream_FallsBackToPolling(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("standalone_server", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		service := &ReadingService{DB: db, MongoClient: mt.Client, Sync: SyncConfig{
+			MongoDBName: "testdb", MongoCollection: "testcoll", StreamPollInterval: time.Hour,
+		}}
+
+		tokenBytes, _ := bson.Marshal(bson.D{{Key: "_data", Value: "8263A1"}})
+		mock.ExpectQuery("SELECT resume_token FROM sync_checkpoints").
+			WillReturnRows(sqlmock.NewRows([]string{"resume_token"}).AddRow(tokenBytes))
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    codeChangeStreamUnsupported,
+			Message: "The $changeStream stage is only supported on replica sets",
+		}))
+		// The first poll runs straight away
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testdb.testcoll", mtest.FirstBatch))
+		mock.ExpectExec("INSERT INTO etl_runs").
+			WithArgs(sqlmock.AnyArg(), "reading", "stream", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		service.StartStream(ctx)
+		deadline := time.Now().Add(2 * time.Second)
+		for mock.ExpectationsWereMet() != nil {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for the polling run: %v", mock.ExpectationsWereMet())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := testutil.ToFloat64(etlStreamMode.WithLabelValues("polling")); got != 1 {
+			t.Errorf("expected polling mode, got %v", got)
+		}
+
+		cancel()
+		if err := service.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	})
+}
+
+func TestStream_SpoolSourcePolls(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	dir, _ := writeSpool(t, `{"_id":{"$oid":"`+primitive.NewObjectID().Hex()+`"},"status":"processed"}`)
+	service := &ReadingService{DB: db, Source: &SpoolSource{Dir: dir}, Sync: SyncConfig{StreamPollInterval: time.Hour}}
+
+	// No change stream and no checkpoint: the first poll runs straight away
+	mock.ExpectExec("INSERT INTO etl_runs").
+		WithArgs(sqlmock.AnyArg(), "reading", "stream", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	service.StartStream(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for mock.ExpectationsWereMet() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the polling run: %v", mock.ExpectationsWereMet())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(etlStreamMode.WithLabelValues("polling")); got != 1 {
+		t.Errorf("expected polling mode, got %v", got)
+	}
+
+	cancel()
+	if err := service.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+}
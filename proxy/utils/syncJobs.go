@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Sync job states.
+const (
+	JobRunning     = "running"
+	JobSucceeded   = "succeeded"
+	JobFailed      = "failed"
+	JobInterrupted = "interrupted"
+)
+
+const (
+	// maxJobErrors caps the errors kept per job; the rest are only logged.
+	maxJobErrors = 20
+	// maxRetainedJobs is how many finished jobs stay queryable.
+	maxRetainedJobs = 100
+)
+
+// SyncJob is the reported state of one sync run.
+type SyncJob struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Trigger  string `json:"trigger"`
+	Fetched  int    `json:"fetched"`
+	Inserted int    `json:"inserted"`
+	Skipped  int    `json:"skipped"` // already in Postgres
+	Acked    int    `json:"acked"`
+	Failed   int    `json:"failed"`
+	// Drained is true when the run stopped because no pending documents
+	// were left to fetch, rather than on a budget. Remaining is the number of
+	// pending documents the run did not get to, when known; documents that
+	// failed in the run are counted in Failed instead.
+	Drained    bool             `json:"drained"`
+	Remaining  *int64           `json:"remaining,omitempty"`
+	Errors     []string         `json:"errors,omitempty"`
+	Validation ValidationReport `json:"validation"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	// DocsPerSecond is the written (inserted or skipped) throughput, set
+	// when the job finishes.
+	DocsPerSecond float64 `json:"docs_per_second,omitempty"`
+}
+
+// ValidationReport counts how the run's payloads fared against the schema
+// registry. Quarantined documents are not counted as Failed.
+type ValidationReport struct {
+	Validated   int      `json:"validated"`
+	NoSchema    int      `json:"no_schema"`
+	Quarantined int      `json:"quarantined"`
+	Failures    []string `json:"failures,omitempty"`
+}
+
+// syncJob is a SyncJob that is updated by the sync while handlers read it.
+type syncJob struct {
+	mu    sync.Mutex
+	state SyncJob
+}
+
+func (j *syncJob) snapshot() SyncJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := j.state
+	s.Errors = append([]string(nil), j.state.Errors...)
+	s.Validation.Failures = append([]string(nil), j.state.Validation.Failures...)
+	return s
+}
+
+func (j *syncJob) update(fn func(*SyncJob)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.state)
+}
+
+// fail counts a failed document and keeps its error for the job report.
+func (j *syncJob) fail(err string) {
+	j.update(func(s *SyncJob) {
+		s.Failed++
+		if len(s.Errors) < maxJobErrors {
+			s.Errors = append(s.Errors, err)
+		}
+	})
+}
+
+// quarantine counts a document that failed validation and keeps the details.
+func (j *syncJob) quarantine(details string) {
+	j.update(func(s *SyncJob) {
+		s.Validation.Quarantined++
+		if len(s.Validation.Failures) < maxJobErrors {
+			s.Validation.Failures = append(s.Validation.Failures, details)
+		}
+	})
+}
+
+func (j *syncJob) finish(status string) {
+	now := time.Now().UTC()
+	j.update(func(s *SyncJob) {
+		s.Status = status
+		s.FinishedAt = &now
+		if secs := now.Sub(s.StartedAt).Seconds(); secs > 0 {
+			s.DocsPerSecond = float64(s.Inserted+s.Skipped) / secs
+		}
+	})
+}
+
+// syncJobs tracks the running job and recently finished ones. Only one job
+// runs at a time so two runs never pick up the same documents.
+type syncJobs struct {
+	mu      sync.Mutex
+	byID    map[string]*syncJob
+	order   []string
+	current *syncJob
+	wg      sync.WaitGroup
+}
+
+// start registers a new running job, or returns the running one and false.
+func (js *syncJobs) start(trigger string) (*syncJob, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	if js.current != nil {
+		return js.current, false
+	}
+	if js.byID == nil {
+		js.byID = make(map[string]*syncJob)
+	}
+
+	job := &syncJob{state: SyncJob{
+		ID:        rand.Text(),
+		Status:    JobRunning,
+		Trigger:   trigger,
+		StartedAt: time.Now().UTC(),
+	}}
+	js.byID[job.state.ID] = job
+	js.order = append(js.order, job.state.ID)
+	if len(js.order) > maxRetainedJobs {
+		delete(js.byID, js.order[0])
+		js.order = js.order[1:]
+	}
+	js.current = job
+	return job, true
+}
+
+func (js *syncJobs) done(job *syncJob) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if js.current == job {
+		js.current = nil
+	}
+}
+
+func (js *syncJobs) get(id string) (*syncJob, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	job, ok := js.byID[id]
+	return job, ok
+}
+
+// SyncJobHandler reports a sync job by the {id} path value.
+func (s *ReadingService) SyncJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.snapshot())
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSyncJobs_OneAtATime(t *testing.T) {
+	var jobs syncJobs
+
+	first, started := jobs.start(TriggerAPI)
+	if !started {
+		t.Fatal("expected the first job to start")
+	}
+	if running, started := jobs.start(TriggerAPI); started || running != first {
+		t.Error("expected a second start to return the running job")
+	}
+
+	jobs.done(first)
+	if _, started := jobs.start(TriggerAPI); !started {
+		t.Error("expected a new job to start once the first is done")
+	}
+}
+
+func TestSyncJobs_Retention(t *testing.T) {
+	var jobs syncJobs
+
+	first, _ := jobs.start(TriggerAPI)
+	jobs.done(first)
+	for range maxRetainedJobs {
+		job, _ := jobs.start(TriggerAPI)
+		jobs.done(job)
+	}
+
+	if _, ok := jobs.get(first.snapshot().ID); ok {
+		t.Error("expected the oldest job to be evicted")
+	}
+	if len(jobs.byID) != maxRetainedJobs {
+		t.Errorf("expected %d retained jobs, got %d", maxRetainedJobs, len(jobs.byID))
+	}
+}
+
+func TestSyncJobHandler(t *testing.T) {
+	service := &ReadingService{}
+	job, _ := service.jobs.start(TriggerAPI)
+	id := job.snapshot().ID
+
+	tests := []struct {
+		name           string
+		method         string
+		id             string
+		expectedStatus int
+	}{
+		{name: "found", method: "GET", id: id, expectedStatus: http.StatusOK},
+		{name: "not_found", method: "GET", id: "missing", expectedStatus: http.StatusNotFound},
+		{name: "wrong_method", method: "DELETE", id: id, expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/sync/jobs/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+			service.SyncJobHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ VOLUMES=(
   grafana_data
   loki_data
   promtail_data
+  prometheus_data
 )
 
 # --- LOGGING ---
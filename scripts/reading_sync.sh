@@ -0,0 +1,32 @@
+#!/bin/bash
+set -euo pipefail
+
+PROXY_URL=${PROXY_URL:-"http://localhost:8085"}
+TOKEN_FILE="${CREDENTIALS_DIRECTORY:-/etc/credstore}/sync_token"
+
+# Log helper using jq for safe JSON generation
+log() {
+    local level=$1
+    local msg=$2
+    jq -n -c \
+        --arg service "reading-sync" \
+        --arg level "$level" \
+        --arg msg "$msg" \
+        '{service: $service, level: $level, msg: $msg}'
+}
+
+if [[ ! -r "$TOKEN_FILE" ]]; then
+    log "ERROR" "Sync token not readable at ${TOKEN_FILE}."
+    exit 1
+fi
+
+# wait=true keeps the run inside the request so the exit code reflects the result.
+# Pass the header through a file descriptor so the token never shows up in argv
+if OUTPUT=$(curl -fsS -X POST \
+    -H @<(printf 'Authorization: Bearer %s\n' "$(cat "$TOKEN_FILE")") \
+    "${PROXY_URL}/api/sync/reading?wait=true&trigger=timer" 2>&1); then
+    log "INFO" "$(echo "$OUTPUT" | head -c 2048)"
+else
+    log "ERROR" "Sync request failed: $(echo "$OUTPUT" | head -c 2048)"
+    exit 1
+fi
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+
+	"config"
+	"db"
+	"logger"
+)
+
+// Config is the collector's startup configuration.
+type Config struct {
+	// StateDir holds the log dedup window between runs; systemd sets it
+	// through StateDirectory=.
+	StateDir string `env:"STATE_DIRECTORY"`
+	// Log holds LOG_LEVEL and LOKI_URL; LogLevel overrides the level for
+	// the collector only.
+	Log      logger.Settings
+	LogLevel string `env:"LOG_LEVEL_SYSTEM_METRICS"`
+	DB       db.ConnectConfig
+}
+
+// LogSettings returns the logger settings with the collector's own level.
+func (c *Config) LogSettings() logger.Settings {
+	s := c.Log
+	s.ServiceLevel = c.LogLevel
+	return s
+}
+
+// loadConfig reads the collector configuration from, lowest precedence
+// first, the built-in defaults, an optional YAML file (CONFIG_FILE, default
+// config.yaml), .env / ../.env and the environment.
+func loadConfig() (Config, error) {
+	var cfg Config
+	err := config.LoadService(&cfg)
+	if cfg.StateDir == "" {
+		cfg.StateDir = os.TempDir()
+	}
+	return cfg, err
+}
@@ -3,27 +3,48 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
+	"config"
 	"db"
 	"logger"
 	"system-metrics/collectors"
 
-	"github.com/jackc/pgx/v5"
-	"github.com/joho/godotenv"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/shirou/gopsutil/v4/host"
 )
 
 func main() {
-	// Initialize structured logging
-	logger.Setup("system-metrics")
-
-	// Load .env (current or parent)
-	_ = godotenv.Load()
-	_ = godotenv.Load("../.env")
+	migrateCmd := flag.String("migrate", "", "run schema migrations (up|status) and exit")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration (secrets masked) and exit")
+	flag.Parse()
+
+	cfg, cfgErr := loadConfig()
+
+	// Initialize structured logging. The collector runs every minute, so
+	// identical records are only written once per hour; the window is kept in
+	// $STATE_DIRECTORY (set by systemd's StateDirectory=) across runs.
+	logger.Setup("system-metrics", logger.WithSettings(cfg.LogSettings()), logger.WithDedup(logger.DedupConfig{
+		Window:    time.Hour,
+		StatePath: filepath.Join(cfg.StateDir, "system-metrics-log-state.json"),
+	}))
+	defer logger.Shutdown(context.Background())
+
+	if cfgErr != nil {
+		slog.Error("config_invalid", "error", cfgErr)
+		os.Exit(1)
+	}
+	if *printConfig {
+		config.Fprint(os.Stdout, cfg)
+		return
+	}
+	slog.Debug("config_loaded", "config", config.LogValue(cfg))
 
 	// 1. Initial Detection
 	hInfo, err := host.Info()
@@ -38,28 +59,33 @@ func main() {
 		hostName = "homelab"
 	}
 
-	// 2. Database Connection
-	connStr, err := db.GetPostgresDSN()
-	if err != nil {
-		slog.Error("db_config_failed", "error", err)
-		os.Exit(1)
-	}
+	// 2. Database Connection (retries until DB_CONNECT_TIMEOUT)
 	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, connStr)
+	pool, err := db.OpenPgxPool(ctx, cfg.DB)
 	if err != nil {
 		slog.Error("db_connection_failed", "error", err)
 		os.Exit(1)
 	}
-	defer conn.Close(ctx)
+	defer pool.Close()
 
 	// 3. Ensure Schema
-	ensureSchema(ctx, conn)
+	if *migrateCmd != "" {
+		if err := runMigrations(ctx, pool, *migrateCmd); err != nil {
+			slog.Error("migration_failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := runMigrations(ctx, pool, ""); err != nil {
+		slog.Error("schema_init_failed", "error", err)
+		os.Exit(1)
+	}
 
 	// 4. Collect and Store Once
-	collectAndStore(ctx, conn, hostName, osName)
+	collectAndStore(ctx, pool, hostName, osName)
 }
 
-func collectAndStore(ctx context.Context, conn *pgx.Conn, hostName string, osName string) {
+func collectAndStore(ctx context.Context, pool *pgxpool.Pool, hostName string, osName string) {
 	now := time.Now().UTC().Truncate(time.Second)
 
 	// Collect
@@ -85,7 +111,7 @@ func collectAndStore(ctx context.Context, conn *pgx.Conn, hostName string, osNam
 			continue
 		}
 		payloadJSON, _ := json.Marshal(m.payload)
-		_, err := conn.Exec(ctx,
+		_, err := pool.Exec(ctx,
 			"INSERT INTO system_metrics (time, host, os, metric_type, payload) VALUES ($1, $2, $3, $4, $5)",
 			now, hostName, osName, m.mType, payloadJSON,
 		)
@@ -95,35 +121,27 @@ func collectAndStore(ctx context.Context, conn *pgx.Conn, hostName string, osNam
 		}
 	}
 
-	// Log success only at the top of the hour and if no errors
-	if now.Minute() == 0 {
-		if len(insertErrors) == 0 {
-			slog.Info("metrics_collected", "status", "success")
-		} else {
-			slog.Warn("metrics_collected", "status", "partial_failure", "error_count", len(insertErrors))
-		}
+	// Repeats within the hour are suppressed by the dedup handler
+	if len(insertErrors) == 0 {
+		slog.Info("metrics_collected", "status", "success")
+	} else {
+		slog.Warn("metrics_collected", "status", "partial_failure", "error_count", len(insertErrors))
 	}
 }
 
-func ensureSchema(ctx context.Context, conn *pgx.Conn) {
-	_, err := conn.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS system_metrics (
-			time TIMESTAMPTZ(0) NOT NULL,
-			host TEXT NOT NULL,
-			os TEXT NOT NULL,
-			metric_type TEXT NOT NULL,
-			payload JSONB NOT NULL
-		);
-	`)
-	if err != nil {
-		slog.Error("schema_init_failed", "error", err)
-		os.Exit(1)
+// runMigrations applies the shared pkg/db migrations, or runs the given
+// "-migrate" command and prints its report.
+func runMigrations(ctx context.Context, pool *pgxpool.Pool, command string) error {
+	sqlDB := stdlib.OpenDBFromPool(pool)
+	defer sqlDB.Close()
+
+	if command != "" {
+		return db.RunMigrateCommand(ctx, sqlDB, command, os.Stdout)
 	}
 
-	// Enable hypertable if TimescaleDB is available
-	_, err = conn.Exec(ctx, "SELECT create_hypertable('system_metrics', 'time', if_not_exists => true);")
-	if err != nil {
-		// Just info, as we might be running on standard Postgres
-		slog.Info("hypertable_check", "status", "skipped_or_failed", "detail", err)
+	applied, err := db.Migrate(ctx, sqlDB)
+	for _, m := range applied {
+		slog.Info("migration_applied", "version", m.Version, "name", m.Name)
 	}
+	return err
 }